@@ -21,7 +21,6 @@
 package cmd
 
 import (
-	"encoding/csv"
 	"fmt"
 	"runtime"
 
@@ -50,20 +49,11 @@ var addHeaderCmd = &cobra.Command{
 			}
 		}
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
-		writer := csv.NewWriter(outfh)
-		if config.OutTabs || config.Tabs {
-			if config.OutDelimiter == ',' {
-				writer.Comma = '\t'
-			} else {
-				writer.Comma = config.OutDelimiter
-			}
-		} else {
-			writer.Comma = config.OutDelimiter
-		}
+		writer := newRecordWriter(config, outfh)
 		defer func() {
 			writer.Flush()
 			checkError(writer.Error())
@@ -82,6 +72,10 @@ var addHeaderCmd = &cobra.Command{
 				checkError(err)
 			}
 
+			// the input has no header row, so the first row is not labeled
+			// as the header row in provenance columns
+			csvReader.NoHeaderRow = true
+
 			csvReader.Read(ReadOption{
 				FieldStr: "1-",
 			})
@@ -0,0 +1,478 @@
+// Copyright © 2016-2023 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// binCmd represents the bin command
+var binCmd = &cobra.Command{
+	GroupID: "edit",
+
+	Use:   "bin",
+	Short: "bin values of a numeric field and append a column of bin labels",
+	Long: `bin values of a numeric field and append a column of bin labels
+
+Binning methods (choose one):
+  -w/--width       fixed width, starting from the minimum value or --start.
+  -n/--bins        fixed number of bins with equal width between the minimum
+                   and maximum values.
+  -q/--quantiles   fixed number of bins with equal frequency, using quantiles
+                   as breakpoints. Duplicated breakpoints are removed.
+  -b/--breaks      user-supplied breakpoints, e.g., -b 0,10,20,50.
+                   Values out of the range are treated as NA.
+
+Bins are left-closed, i.e., [lower,upper), use -r/--right-closed for
+right-closed bins, i.e., (lower,upper]. For -n/--bins, -q/--quantiles and
+-b/--breaks, the last (first for -r/--right-closed) bin is closed on both
+sides, to include the maximum (minimum) value.
+
+Bin labels (--label-format) support these placeholders:
+  {lower}   lower bound of the bin
+  {upper}   upper bound of the bin
+  {index}   index of the bin, starting from 1
+
+Counting mode (-c/--count) outputs a text histogram, with columns:
+  bin, lower, upper, count, proportion, density
+
+  density: count / (number of binned values * bin width)
+
+Attention:
+  1. All records are kept in memory except for -b/--breaks, or -w/--width
+     with --start, in which bins are known before reading data.
+  2. Non-numeric values are not allowed unless -i/--ignore-non-numbers
+     is given, and the bin labels of them are --na.
+  3. In counting mode with -w/--width, all bins (including empty ones)
+     between the minimum and maximum values are outputted, the number of
+     which is limited by --max-bins, to avoid exhausting memory for outliers.
+
+Examples:
+
+  $ csvtk bin -f score -w 10 --start 0 data.csv
+  $ csvtk bin -f score -q 4 --label-format "Q{index}" data.csv
+  $ csvtk bin -f score -b 0,60,80,100 -r -c data.csv
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if len(files) > 1 {
+			checkError(fmt.Errorf("no more than one file should be given"))
+		}
+		runtime.GOMAXPROCS(config.NumCPUs)
+
+		field := getFlagString(cmd, "field")
+		if field == "" {
+			checkError(fmt.Errorf("flag -f/--field needed"))
+		}
+		if strings.Contains(field, ",") {
+			checkError(fmt.Errorf("only one field is allowed for -f/--field"))
+		}
+
+		width := getFlagFloat64(cmd, "width")
+		nBins := getFlagNonNegativeInt(cmd, "bins")
+		nQuantiles := getFlagNonNegativeInt(cmd, "quantiles")
+		breaksStr := getFlagString(cmd, "breaks")
+		startSet := cmd.Flags().Lookup("start").Changed
+		start := getFlagFloat64(cmd, "start")
+
+		var nMethods int
+		for _, ok := range []bool{width != 0, nBins > 0, nQuantiles > 0, breaksStr != ""} {
+			if ok {
+				nMethods++
+			}
+		}
+		if nMethods != 1 {
+			checkError(fmt.Errorf("one and only one of -w/--width, -n/--bins, -q/--quantiles and -b/--breaks should be given"))
+		}
+		if width < 0 {
+			checkError(fmt.Errorf("the value of -w/--width should be positive"))
+		}
+		if startSet && width == 0 {
+			checkError(fmt.Errorf("flag --start only works with -w/--width"))
+		}
+
+		var breaks []float64
+		if breaksStr != "" {
+			for _, s := range strings.Split(breaksStr, ",") {
+				v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+				if err != nil {
+					checkError(fmt.Errorf("invalid breakpoint: %s", s))
+				}
+				breaks = append(breaks, v)
+			}
+			if len(breaks) < 2 {
+				checkError(fmt.Errorf("at least two breakpoints needed for -b/--breaks"))
+			}
+			for i := 1; i < len(breaks); i++ {
+				if breaks[i] <= breaks[i-1] {
+					checkError(fmt.Errorf("breakpoints should be in ascending order and unique: %s", breaksStr))
+				}
+			}
+		}
+
+		rightClosed := getFlagBool(cmd, "right-closed")
+		labelFormat := getFlagString(cmd, "label-format")
+		if labelFormat == "" {
+			if rightClosed {
+				labelFormat = "({lower},{upper}]"
+			} else {
+				labelFormat = "[{lower},{upper})"
+			}
+		}
+		na := getFlagString(cmd, "na")
+		name := getFlagString(cmd, "name")
+		ignore := getFlagBool(cmd, "ignore-non-numbers")
+		countMode := getFlagBool(cmd, "count")
+		maxBins := getFlagNonNegativeInt(cmd, "max-bins")
+		decimalWidth := getFlagNonNegativeInt(cmd, "decimal-width")
+
+		formatNum := func(v float64) string {
+			p := math.Pow10(decimalWidth)
+			return strconv.FormatFloat(math.Round(v*p)/p, 'f', -1, 64)
+		}
+
+		outfh, err := wopenByConfig(config)
+		checkError(err)
+		defer outfh.Close()
+
+		writer := newRecordWriter(config, outfh)
+		defer func() {
+			writer.Flush()
+			checkError(writer.Error())
+		}()
+
+		file := files[0]
+		csvReader, err := newCSVReaderByConfig(config, file)
+
+		if err != nil {
+			if err == xopen.ErrNoContent {
+				if config.Verbose {
+					log.Warningf("csvtk bin: skipping empty input file: %s", file)
+				}
+
+				writer.Flush()
+				checkError(writer.Error())
+				readerReport(&config, csvReader, file)
+				return
+			}
+			checkError(err)
+		}
+
+		csvReader.Read(ReadOption{
+			FieldStr: field,
+
+			DoNotAllowDuplicatedColumnName: true,
+		})
+
+		// bins are known before reading data
+		streaming := breaks != nil || startSet
+
+		var binner *valueBinner
+		if streaming {
+			binner = &valueBinner{breaks: breaks, width: width, start: start, rightClosed: rightClosed}
+		}
+
+		label := func(idx int) string {
+			if idx < 0 && binner.width == 0 {
+				return na
+			}
+			lower, upper := binner.bounds(idx)
+			s := strings.ReplaceAll(labelFormat, "{lower}", formatNum(lower))
+			s = strings.ReplaceAll(s, "{upper}", formatNum(upper))
+			return strings.ReplaceAll(s, "{index}", strconv.Itoa(idx+1))
+		}
+
+		type binRecord struct {
+			record []string
+			v      float64
+			ok     bool
+		}
+		var records []binRecord
+		var values []float64
+		counts := make(map[int]int, 64)
+
+		var v float64
+		var ok bool
+		checkFirstLine := true
+		for record := range csvReader.Ch {
+			if record.Err != nil {
+				checkError(record.Err)
+			}
+
+			if checkFirstLine {
+				checkFirstLine = false
+				if len(record.Fields) != 1 {
+					checkError(fmt.Errorf("only one field is allowed for -f/--field"))
+				}
+				if !config.NoHeaderRow || record.IsHeaderRow {
+					if !countMode && !config.NoOutHeader {
+						checkError(writer.Write(append(record.All, name)))
+					}
+					continue
+				}
+			}
+
+			ok = reDigitals.MatchString(record.Selected[0])
+			if ok {
+				v, err = strconv.ParseFloat(removeComma(record.Selected[0]), 64)
+				checkError(err)
+			} else if !ignore {
+				checkError(fmt.Errorf("[line %d] non-numeric value: %s, you can use flag -i/--ignore-non-numbers to skip these data", record.Line, record.Selected[0]))
+			}
+
+			if ok && !streaming { // values are only needed for computing bins
+				values = append(values, v)
+			}
+
+			if streaming {
+				if countMode {
+					if ok {
+						counts[binner.index(v)]++
+					}
+					continue
+				}
+				if ok {
+					checkError(writer.Write(append(record.All, label(binner.index(v)))))
+				} else {
+					checkError(writer.Write(append(record.All, na)))
+				}
+				continue
+			}
+
+			if !countMode {
+				records = append(records, binRecord{record: record.All, v: v, ok: ok})
+			}
+		}
+
+		readerReport(&config, csvReader, file)
+
+		if !streaming {
+			binner = &valueBinner{width: width, rightClosed: rightClosed}
+			if len(values) > 0 {
+				sorted := values
+				if nQuantiles > 0 {
+					sorted = append([]float64{}, values...)
+					sort.Float64s(sorted)
+				}
+				min, max := values[0], values[0]
+				for _, v = range values {
+					if v < min {
+						min = v
+					}
+					if v > max {
+						max = v
+					}
+				}
+				switch {
+				case width > 0:
+					binner.start = min
+				case nBins > 0:
+					if min == max {
+						binner.breaks = []float64{min, max}
+					} else {
+						step := (max - min) / float64(nBins)
+						binner.breaks = make([]float64, nBins+1)
+						for i := range binner.breaks {
+							binner.breaks[i] = min + float64(i)*step
+						}
+						binner.breaks[nBins] = max
+					}
+				case nQuantiles > 0:
+					binner.breaks = make([]float64, 0, nQuantiles+1)
+					for i := 0; i <= nQuantiles; i++ {
+						q := percentileValue(sorted, float64(i)/float64(nQuantiles))
+						if len(binner.breaks) > 0 && q == binner.breaks[len(binner.breaks)-1] {
+							continue
+						}
+						binner.breaks = append(binner.breaks, q)
+					}
+					if len(binner.breaks) == 1 {
+						binner.breaks = append(binner.breaks, binner.breaks[0])
+					}
+				}
+			}
+
+			if countMode {
+				for _, v = range values {
+					counts[binner.index(v)]++
+				}
+			} else {
+				for _, r := range records {
+					if r.ok && len(values) > 0 {
+						checkError(writer.Write(append(r.record, label(binner.index(r.v)))))
+					} else {
+						checkError(writer.Write(append(r.record, na)))
+					}
+				}
+			}
+		}
+
+		if !countMode {
+			return
+		}
+
+		// text histogram
+		if !config.NoOutHeader {
+			checkError(writer.Write([]string{"bin", "lower", "upper", "count", "proportion", "density"}))
+		}
+		var idxs []int
+		if binner.width > 0 {
+			if len(counts) > 0 {
+				first := true
+				var minIdx, maxIdx int
+				for idx := range counts {
+					if first || idx < minIdx {
+						minIdx = idx
+					}
+					if first || idx > maxIdx {
+						maxIdx = idx
+					}
+					first = false
+				}
+				if n := int64(maxIdx) - int64(minIdx) + 1; maxBins > 0 && n > int64(maxBins) {
+					lower, _ := binner.bounds(minIdx)
+					_, upper := binner.bounds(maxIdx)
+					checkError(fmt.Errorf("too many bins (%d) from %s to %s, please use a larger width, check outliers, or increase --max-bins",
+						n, formatNum(lower), formatNum(upper)))
+				}
+				for idx := minIdx; idx <= maxIdx; idx++ {
+					idxs = append(idxs, idx)
+				}
+			}
+		} else if len(binner.breaks) > 0 {
+			for idx := 0; idx < len(binner.breaks)-1; idx++ {
+				idxs = append(idxs, idx)
+			}
+		}
+		var total int
+		for _, idx := range idxs {
+			total += counts[idx]
+		}
+		var lower, upper, proportion, density float64
+		for _, idx := range idxs {
+			lower, upper = binner.bounds(idx)
+			proportion, density = 0, 0
+			if total > 0 {
+				proportion = float64(counts[idx]) / float64(total)
+				if upper > lower {
+					density = proportion / (upper - lower)
+				}
+			}
+			checkError(writer.Write([]string{label(idx), formatNum(lower), formatNum(upper),
+				strconv.Itoa(counts[idx]), formatNum(proportion), formatNum(density)}))
+		}
+	},
+}
+
+// valueBinner assigns values to bins of fixed width, or bins given by breakpoints.
+type valueBinner struct {
+	breaks      []float64 // breakpoints in ascending order
+	width       float64   // for bins of fixed width
+	start       float64   // start of the first bin, for bins of fixed width
+	rightClosed bool
+}
+
+// index returns the index (0-based) of the bin, or -1 for values out of breakpoints.
+func (b *valueBinner) index(v float64) int {
+	if b.width > 0 {
+		x := (v - b.start) / b.width
+		// correct floating-point errors with bounds, e.g., (0.3 - 0) / 0.1 = 2.9999999999999996
+		var idx int
+		if b.rightClosed {
+			idx = int(math.Ceil(x)) - 1
+			if b.bound(idx) >= v {
+				idx--
+			} else if b.bound(idx+1) < v {
+				idx++
+			}
+			return idx
+		}
+		idx = int(math.Floor(x))
+		if b.bound(idx+1) <= v {
+			idx++
+		} else if b.bound(idx) > v {
+			idx--
+		}
+		return idx
+	}
+
+	n := len(b.breaks)
+	if n < 2 || v < b.breaks[0] || v > b.breaks[n-1] {
+		return -1
+	}
+	if b.rightClosed {
+		if v == b.breaks[0] {
+			return 0
+		}
+		// the first breakpoint >= v
+		return sort.SearchFloat64s(b.breaks, v) - 1
+	}
+	if v == b.breaks[n-1] {
+		return n - 2
+	}
+	// the first breakpoint > v
+	return sort.Search(n, func(i int) bool { return b.breaks[i] > v }) - 1
+}
+
+// bounds returns the lower and upper bounds of a bin.
+func (b *valueBinner) bounds(idx int) (float64, float64) {
+	if b.width > 0 {
+		return b.bound(idx), b.bound(idx + 1)
+	}
+	return b.breaks[idx], b.breaks[idx+1]
+}
+
+// bound returns the i-th bound of bins of fixed width, rounded to 15
+// significant digits to remove floating-point errors, e.g., 3 * 0.1
+// is 0.30000000000000004.
+func (b *valueBinner) bound(i int) float64 {
+	x, _ := strconv.ParseFloat(strconv.FormatFloat(b.start+float64(i)*b.width, 'g', 15, 64), 64)
+	return x
+}
+
+func init() {
+	RootCmd.AddCommand(binCmd)
+
+	binCmd.Flags().StringP("field", "f", "", `numeric field to bin. e.g -f 1 or -f columnA`)
+	binCmd.Flags().Float64P("width", "w", 0, `bin width`)
+	binCmd.Flags().Float64P("start", "", 0, `start of the first bin for -w/--width (default: the minimum value)`)
+	binCmd.Flags().IntP("bins", "n", 0, `number of bins with equal width`)
+	binCmd.Flags().IntP("quantiles", "q", 0, `number of bins with equal frequency`)
+	binCmd.Flags().StringP("breaks", "b", "", `comma-separated breakpoints in ascending order, e.g., -b 0,10,20,50`)
+	binCmd.Flags().BoolP("right-closed", "r", false, `bins are right-closed, i.e., (lower,upper]`)
+	binCmd.Flags().StringP("label-format", "", "", `format of bin labels, supported placeholders: {lower}, {upper}, {index} (default "[{lower},{upper})")`)
+	binCmd.Flags().StringP("name", "", "bin", `column name of bin labels`)
+	binCmd.Flags().StringP("na", "", "", `bin label for non-numeric values or values out of breakpoints`)
+	binCmd.Flags().BoolP("ignore-non-numbers", "i", false, `ignore non-numeric values like "NA" or "N/A"`)
+	binCmd.Flags().BoolP("count", "c", false, `count values in each bin, outputting a text histogram`)
+	binCmd.Flags().IntP("max-bins", "", 1000000, `maximum number of bins in counting mode with -w/--width, 0 for no limit`)
+	binCmd.Flags().IntP("decimal-width", "", 4, `limit floats to N decimal points in bin labels`)
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValueBinnerIndex(t *testing.T) {
+	cases := []struct {
+		binner valueBinner
+		v      float64
+		expect int
+	}{
+		// fixed width, left-closed
+		{valueBinner{width: 1}, 0, 0},
+		{valueBinner{width: 1}, 0.5, 0},
+		{valueBinner{width: 1}, 1, 1},
+		{valueBinner{width: 1}, -0.5, -1},
+		{valueBinner{width: 10, start: 5}, 15, 1},
+		{valueBinner{width: 10, start: 5}, 4.9, -1},
+		// floating-point errors
+		{valueBinner{width: 0.1}, 0.3, 3},
+		{valueBinner{width: 0.1}, 0.7, 7},
+		{valueBinner{width: 0.1}, 0.29999, 2},
+		{valueBinner{width: 0.1, start: 0.2}, 0.6, 4},
+
+		// fixed width, right-closed
+		{valueBinner{width: 1, rightClosed: true}, 1, 0},
+		{valueBinner{width: 1, rightClosed: true}, 1.5, 1},
+		{valueBinner{width: 1, rightClosed: true}, 0, -1},
+		{valueBinner{width: 0.1, rightClosed: true}, 0.3, 2},
+		{valueBinner{width: 0.1, rightClosed: true}, 0.30001, 3},
+		{valueBinner{width: 0.1, rightClosed: true}, 0.7, 6},
+
+		// breakpoints, left-closed, the last bin is closed on both sides
+		{valueBinner{breaks: []float64{0, 10, 20}}, 0, 0},
+		{valueBinner{breaks: []float64{0, 10, 20}}, 10, 1},
+		{valueBinner{breaks: []float64{0, 10, 20}}, 20, 1},
+		{valueBinner{breaks: []float64{0, 10, 20}}, -1, -1},
+		{valueBinner{breaks: []float64{0, 10, 20}}, 21, -1},
+
+		// breakpoints, right-closed, the first bin is closed on both sides
+		{valueBinner{breaks: []float64{0, 10, 20}, rightClosed: true}, 0, 0},
+		{valueBinner{breaks: []float64{0, 10, 20}, rightClosed: true}, 10, 0},
+		{valueBinner{breaks: []float64{0, 10, 20}, rightClosed: true}, 10.5, 1},
+		{valueBinner{breaks: []float64{0, 10, 20}, rightClosed: true}, 20, 1},
+
+		// one breakpoint
+		{valueBinner{breaks: []float64{1}}, 1, -1},
+	}
+
+	for _, c := range cases {
+		if got := c.binner.index(c.v); got != c.expect {
+			t.Errorf("valueBinner%+v.index(%v): want %d, got %d", c.binner, c.v, c.expect, got)
+		}
+	}
+}
+
+func TestValueBinnerBounds(t *testing.T) {
+	cases := []struct {
+		binner       valueBinner
+		idx          int
+		lower, upper float64
+	}{
+		{valueBinner{width: 0.1}, 0, 0, 0.1},
+		{valueBinner{width: 0.1}, 2, 0.2, 0.3},
+		{valueBinner{width: 0.1}, 7, 0.7, 0.8},
+		{valueBinner{width: 0.1}, -3, -0.3, -0.2},
+		{valueBinner{width: 2.5, start: 1}, 1, 3.5, 6},
+		{valueBinner{breaks: []float64{0, 10, 20}}, 1, 10, 20},
+	}
+	for _, c := range cases {
+		if lower, upper := c.binner.bounds(c.idx); lower != c.lower || upper != c.upper {
+			t.Errorf("valueBinner%+v.bounds(%d): want [%v %v], got [%v %v]", c.binner, c.idx, c.lower, c.upper, lower, upper)
+		}
+	}
+
+	var b valueBinner
+
+	// each value is in the range of bounds of its bin
+	for _, rightClosed := range []bool{false, true} {
+		b = valueBinner{width: 0.1, start: -1, rightClosed: rightClosed}
+		for i := -100; i <= 100; i++ {
+			v := float64(i) / 100
+			lower, upper := b.bounds(b.index(v))
+			if !rightClosed && !(lower <= v && v < upper) || rightClosed && !(lower < v && v <= upper) {
+				t.Errorf("valueBinner%+v: %v is out of bounds of its bin: [%v, %v]", b, v, lower, upper)
+			}
+		}
+	}
+}
+
+func TestBinCount(t *testing.T) {
+	file := testFile(t, "x.csv", "x\n0.5\n2.5\n2.7\n")
+
+	got := runCsvtk(t, "bin", file, "-f", "x", "-w", "1", "-c", "--max-bins", "3")
+	expect := `bin,lower,upper,count,proportion,density
+"[0.5,1.5)",0.5,1.5,1,0.3333,0.3333
+"[1.5,2.5)",1.5,2.5,0,0,0
+"[2.5,3.5)",2.5,3.5,2,0.6667,0.6667
+`
+	if got != expect {
+		t.Errorf("csvtk bin -c:\nwant %q\ngot  %q", expect, got)
+	}
+
+	// too many bins
+	for _, args := range [][]string{
+		{"-f", "x", "-w", "1", "-c", "--max-bins", "2"},
+		{"-f", "x", "-w", "1e-9", "-c"},
+	} {
+		stderr, err := execCsvtkInSubprocess(t, append([]string{"bin", file}, args...)...)
+		if err == nil || !strings.Contains(stderr, "too many bins") {
+			t.Errorf("csvtk bin %s: want error of too many bins, got %v: %s", strings.Join(args, " "), err, stderr)
+		}
+	}
+
+	// no limit
+	got = runCsvtk(t, "bin", file, "-f", "x", "-w", "0.01", "-c", "--max-bins", "0")
+	if n := strings.Count(got, "\n"); n != 222 {
+		t.Errorf("csvtk bin -c --max-bins 0: want 221 bins, got %d", n-1)
+	}
+}
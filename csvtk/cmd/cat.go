@@ -42,21 +42,18 @@ var catCmd = &cobra.Command{
 
 	Run: func(cmd *cobra.Command, args []string) {
 		config := getConfigs(cmd)
-		outFile := config.OutFile
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
 		flagLines := getFlagBool(cmd, "lines")
 		flagBuff := getFlagInt(cmd, "buffsize")
 		flagFreq := getFlagInt(cmd, "print-freq")
 		flagTotal := getFlagInt(cmd, "total")
 
-		of := os.Stdout
-		defer of.Close()
-		if outFile != "-" {
-			var err error
-			of, err = os.Create(outFile)
-			checkError(err)
-		}
-		writer := bufio.NewWriterSize(of, flagBuff)
+		outfh, err := wopenRaw(config.OutFile, config.BackupSuffix)
+		checkError(err)
+		defer func() {
+			checkError(outfh.Close())
+		}()
+		writer := bufio.NewWriterSize(outfh, flagBuff)
 		defer writer.Flush()
 
 		for _, file := range files {
@@ -72,8 +69,7 @@ var catCmd = &cobra.Command{
 
 			if flagLines {
 				if flagTotal < 0 {
-					fmt.Fprintf(os.Stderr, "Cannot read lines unless the of expected number of lines is specified via -s!\n")
-					os.Exit(1)
+					checkError(fmt.Errorf("cannot read lines unless the expected number of lines is specified via -s"))
 				}
 				bar = pb.StartNew(flagTotal)
 				bar.SetWriter(os.Stderr)
@@ -98,8 +94,7 @@ var catCmd = &cobra.Command{
 				var err error
 				if flagTotal < 0 {
 					if file == "-" {
-						fmt.Fprintf(os.Stderr, "Cannot read from stdin unless the number of expected bytes is specified via -s!\n")
-						os.Exit(1)
+						checkError(fmt.Errorf("cannot read from stdin unless the expected number of bytes is specified via -s"))
 					}
 					inputStat, err := os.Stat(file)
 					checkError(err)
@@ -54,20 +54,11 @@ var combCmd = &cobra.Command{
 		number0 := getFlagNonNegativeInt(cmd, "number")
 		ignoreCase := getFlagBool(cmd, "ignore-case")
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
-		writer := csv.NewWriter(outfh)
-		if config.OutTabs || config.Tabs {
-			if config.OutDelimiter == ',' {
-				writer.Comma = '\t'
-			} else {
-				writer.Comma = config.OutDelimiter
-			}
-		} else {
-			writer.Comma = config.OutDelimiter
-		}
+		writer := newRecordWriter(config, outfh)
 		defer func() {
 			writer.Flush()
 			checkError(writer.Error())
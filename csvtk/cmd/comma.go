@@ -21,7 +21,6 @@
 package cmd
 
 import (
-	"encoding/csv"
 	"fmt"
 	"runtime"
 	"strconv"
@@ -55,20 +54,11 @@ var fmtnumCmd = &cobra.Command{
 
 		fuzzyFields := getFlagBool(cmd, "fuzzy-fields")
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
-		writer := csv.NewWriter(outfh)
-		if config.OutTabs || config.Tabs {
-			if config.OutDelimiter == ',' {
-				writer.Comma = '\t'
-			} else {
-				writer.Comma = config.OutDelimiter
-			}
-		} else {
-			writer.Comma = config.OutDelimiter
-		}
+		writer := newRecordWriter(config, outfh)
 		defer func() {
 			writer.Flush()
 			checkError(writer.Error())
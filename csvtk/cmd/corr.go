@@ -26,11 +26,14 @@ import (
 	"math"
 	"os"
 	"runtime"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/shenwei356/xopen"
 	"github.com/spf13/cobra"
 	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
 )
 
 // corrCmd represents the corr command
@@ -38,8 +41,36 @@ var corrCmd = &cobra.Command{
 	GroupID: "info",
 
 	Use:   "corr",
-	Short: "calculate Pearson correlation between two columns",
-	Long:  "calculate Pearson correlation between two columns",
+	Short: "calculate correlations between columns",
+	Long: `calculate correlations between columns
+
+Correlations of all pairs of selected columns are computed by reading
+the file only once.
+
+Methods (-m/--method):
+  pearson     Pearson correlation coefficient
+  spearman    Spearman's rank correlation coefficient
+  kendall     Kendall rank correlation coefficient (tau-b)
+
+Output formats:
+  default           field1, field2, <method>, [covariance], [pvalue],
+                    tab-delimited without a header row, written to stderr
+  table (--table)   field1, field2, n, <method>, [covariance], [pvalue],
+                    with a header row, written to stdout (or -o/--out-file)
+  wide (-w/--wide)  a matrix of correlation coefficients, or covariances
+                    with --cov, written to stdout (or -o/--out-file)
+
+Attention:
+  1. Non-numeric values are treated as NaN, use -i/--ignore_nan to
+     remove pairs containing NaN (pairwise deletion). Otherwise,
+     correlations of columns containing NaN are NaN.
+  2. The covariance is computed on the raw (or log transformed) values.
+  3. P-values (two-sided) are computed with a t-distribution for Pearson
+     and Spearman, and a normal approximation for Kendall.
+  4. In the passthrough mode (-x/--pass), the input data is written to
+     the output, and the correlations are always written to stderr.
+
+`,
 
 	Run: func(cmd *cobra.Command, args []string) {
 		config := getConfigs(cmd)
@@ -51,20 +82,30 @@ var corrCmd = &cobra.Command{
 		printPass := getFlagBool(cmd, "pass")
 		printLog := getFlagBool(cmd, "log")
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		method := strings.ToLower(getFlagString(cmd, "method"))
+		switch method {
+		case "pearson", "spearman", "kendall":
+		default:
+			checkError(fmt.Errorf("invalid method: %s. available: pearson, spearman, kendall", method))
+		}
+		wide := getFlagBool(cmd, "wide")
+		table := getFlagBool(cmd, "table")
+		if wide && table {
+			checkError(fmt.Errorf("flags -w/--wide and --table are exclusive"))
+		}
+		addCov := getFlagBool(cmd, "cov")
+		addPvalue := getFlagBool(cmd, "pvalue")
+		if wide && addPvalue {
+			checkError(fmt.Errorf("flag -p/--pvalue is not supported for -w/--wide"))
+		}
+		decimalWidth := getFlagNonNegativeInt(cmd, "decimal-width")
+		decimalFormat := fmt.Sprintf("%%.%df", decimalWidth)
+
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
-		writer := csv.NewWriter(outfh)
-		if config.OutTabs || config.Tabs {
-			if config.OutDelimiter == ',' {
-				writer.Comma = '\t'
-			} else {
-				writer.Comma = config.OutDelimiter
-			}
-		} else {
-			writer.Comma = config.OutDelimiter
-		}
+		writer := newRecordWriter(config, outfh)
 		defer func() {
 			writer.Flush()
 			checkError(writer.Error())
@@ -147,9 +188,48 @@ var corrCmd = &cobra.Command{
 
 		readerReport(&config, csvReader, file)
 
-		for col1, field1 := range fields {
-			for col2, field2 := range fields {
-				if col1 >= col2 {
+		names := make([]string, len(fields))
+		for i, f = range fields {
+			if hasHeaderRow {
+				names[i] = HeaderRow[f-1]
+			} else {
+				names[i] = strconv.Itoa(f)
+			}
+		}
+
+		// in the passthrough mode or the default format, results are written to stderr.
+		var outWriter RecordWriter
+		if printPass || !(table || wide) {
+			w := csv.NewWriter(os.Stderr)
+			w.Comma = '\t'
+			outWriter = w
+			defer w.Flush()
+		} else {
+			outWriter = writer
+		}
+
+		var matrix [][]string
+		if wide {
+			matrix = make([][]string, len(fields))
+			for i = range fields {
+				matrix[i] = make([]string, len(fields))
+			}
+		} else if table {
+			header := []string{"field1", "field2", "n", method}
+			if addCov {
+				header = append(header, "covariance")
+			}
+			if addPvalue {
+				header = append(header, "pvalue")
+			}
+			checkError(outWriter.Write(header))
+		}
+
+		var r, p float64
+		var items []string
+		for col1 := range fields {
+			for col2 := range fields {
+				if col1 > col2 || (col1 == col2 && !wide) {
 					continue
 				}
 
@@ -158,14 +238,38 @@ var corrCmd = &cobra.Command{
 					d1, d2 = removeNaNs(d1, d2)
 				}
 
-				pearsonr := stat.Correlation(d1, d2, nil)
+				if wide {
+					if addCov {
+						r = stat.Covariance(d1, d2, nil)
+					} else {
+						r, _ = correlation(d1, d2, method, false)
+					}
+					matrix[col1][col2] = fmt.Sprintf(decimalFormat, r)
+					matrix[col2][col1] = matrix[col1][col2]
+					continue
+				}
 
-				if hasHeaderRow {
-					fmt.Fprintf(os.Stderr, "%s\t%s\t%.4f\n", HeaderRow[field1-1], HeaderRow[field2-1], pearsonr)
+				r, p = correlation(d1, d2, method, addPvalue)
+
+				if table {
+					items = []string{names[col1], names[col2], strconv.Itoa(len(d1)), fmt.Sprintf(decimalFormat, r)}
 				} else {
-					fmt.Fprintf(os.Stderr, "%d\t%d\t%.4f\n", field1, field2, pearsonr)
+					items = []string{names[col1], names[col2], fmt.Sprintf(decimalFormat, r)}
+				}
+				if addCov {
+					items = append(items, fmt.Sprintf(decimalFormat, stat.Covariance(d1, d2, nil)))
 				}
+				if addPvalue {
+					items = append(items, strconv.FormatFloat(p, 'g', 4, 64))
+				}
+				checkError(outWriter.Write(items))
+			}
+		}
 
+		if wide {
+			checkError(outWriter.Write(append([]string{"field"}, names...)))
+			for i, items = range matrix {
+				checkError(outWriter.Write(append([]string{names[i]}, items...)))
 			}
 		}
 	},
@@ -186,6 +290,166 @@ func removeNaNs(d1, d2 []float64) ([]float64, []float64) {
 	return r1, r2
 }
 
+// correlation computes the correlation coefficient of a method,
+// and the two-sided p-value if needed.
+// NaN is returned if there are NaNs in the values, like stat.Correlation.
+func correlation(x, y []float64, method string, pvalue bool) (float64, float64) {
+	n := float64(len(x))
+	p := math.NaN()
+	var r float64
+	if method != "pearson" { // NaNs can not be ranked
+		for i := range x {
+			if math.IsNaN(x[i]) || math.IsNaN(y[i]) {
+				return math.NaN(), math.NaN()
+			}
+		}
+	}
+	switch method {
+	case "kendall":
+		var z float64
+		r, z = kendallTauB(x, y)
+		if pvalue {
+			p = 2 * distuv.UnitNormal.Survival(math.Abs(z))
+		}
+		return r, p
+	case "spearman":
+		r = stat.Correlation(rankValues(x), rankValues(y), nil)
+	default:
+		r = stat.Correlation(x, y, nil)
+	}
+	if pvalue && n > 2 {
+		if math.Abs(r) >= 1 {
+			p = 0
+		} else {
+			t := r * math.Sqrt((n-2)/(1-r*r))
+			p = 2 * distuv.StudentsT{Mu: 0, Sigma: 1, Nu: n - 2}.Survival(math.Abs(t))
+		}
+	}
+	return r, p
+}
+
+// rankValues returns ranks (starting from 1) of values, ties are assigned
+// the average rank.
+func rankValues(x []float64) []float64 {
+	idx := make([]int, len(x))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return x[idx[i]] < x[idx[j]] })
+
+	ranks := make([]float64, len(x))
+	var j int
+	var r float64
+	for i := 0; i < len(idx); i = j {
+		for j = i + 1; j < len(idx) && x[idx[j]] == x[idx[i]]; j++ {
+		}
+		r = float64(i+j+1) / 2 // average of ranks i+1 ... j
+		for k := i; k < j; k++ {
+			ranks[idx[k]] = r
+		}
+	}
+	return ranks
+}
+
+// kendallTauB computes Kendall's tau-b with Knight's O(n log n) algorithm,
+// and the z-score of the normal approximation with tie correction.
+func kendallTauB(x, y []float64) (float64, float64) {
+	n := len(x)
+	if n < 2 {
+		return math.NaN(), math.NaN()
+	}
+
+	type pair struct{ x, y float64 }
+	pairs := make([]pair, n)
+	for i := range x {
+		pairs[i] = pair{x[i], y[i]}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].x == pairs[j].x {
+			return pairs[i].y < pairs[j].y
+		}
+		return pairs[i].x < pairs[j].x
+	})
+
+	// ties of x, and joint ties
+	var xtie, x0, x1, ntie float64
+	var j int
+	for i := 0; i < n; i = j {
+		for j = i + 1; j < n && pairs[j].x == pairs[i].x; j++ {
+		}
+		t := float64(j - i)
+		xtie += t * (t - 1) / 2
+		x0 += t * (t - 1) * (t - 2)
+		x1 += t * (t - 1) * (2*t + 5)
+
+		var l int
+		for k := i; k < j; k = l {
+			for l = k + 1; l < j && pairs[l].y == pairs[k].y; l++ {
+			}
+			t = float64(l - k)
+			ntie += t * (t - 1) / 2
+		}
+	}
+
+	// discordant pairs are the swaps of sorting y
+	ys := make([]float64, n)
+	for i, p := range pairs {
+		ys[i] = p.y
+	}
+	dis := float64(mergeSortCountSwaps(ys, make([]float64, n)))
+
+	// ties of y
+	var ytie, y0, y1 float64
+	for i := 0; i < n; i = j {
+		for j = i + 1; j < n && ys[j] == ys[i]; j++ {
+		}
+		t := float64(j - i)
+		ytie += t * (t - 1) / 2
+		y0 += t * (t - 1) * (t - 2)
+		y1 += t * (t - 1) * (2*t + 5)
+	}
+
+	size := float64(n)
+	tot := size * (size - 1) / 2
+	conMinusDis := tot - xtie - ytie + ntie - 2*dis
+	tau := conMinusDis / math.Sqrt(tot-xtie) / math.Sqrt(tot-ytie)
+
+	m := size * (size - 1)
+	v := (m*(2*size+5)-x1-y1)/18 + (2*xtie*ytie)/m
+	if n > 2 {
+		v += x0 * y0 / (9 * m * (size - 2))
+	}
+	return tau, conMinusDis / math.Sqrt(v)
+}
+
+// mergeSortCountSwaps sorts values in place and returns the number of
+// swaps of a bubble sort, i.e., the number of inversions.
+func mergeSortCountSwaps(a, buf []float64) int {
+	n := len(a)
+	if n < 2 {
+		return 0
+	}
+	mid := n / 2
+	swaps := mergeSortCountSwaps(a[:mid], buf[:mid]) + mergeSortCountSwaps(a[mid:], buf[mid:])
+
+	i, j, k := 0, mid, 0
+	for i < mid && j < n {
+		if a[j] < a[i] {
+			buf[k] = a[j]
+			swaps += mid - i
+			j++
+		} else {
+			buf[k] = a[i]
+			i++
+		}
+		k++
+	}
+	k += copy(buf[k:], a[i:mid])
+	copy(buf[k:], a[j:])
+	copy(a, buf[:n])
+	return swaps
+}
+
 func init() {
 	RootCmd.AddCommand(corrCmd)
 
@@ -193,4 +457,10 @@ func init() {
 	corrCmd.Flags().BoolP("ignore_nan", "i", false, "Ignore non-numeric fields to avoid returning NaN")
 	corrCmd.Flags().BoolP("log", "L", false, "Calcute correlations on Log10 transformed data")
 	corrCmd.Flags().BoolP("pass", "x", false, "passthrough mode (forward input to output)")
+	corrCmd.Flags().StringP("method", "m", "pearson", "correlation method: pearson, spearman, kendall")
+	corrCmd.Flags().BoolP("wide", "w", false, "output a matrix instead of pairs in rows")
+	corrCmd.Flags().BoolP("table", "", false, "output pairs as a table with a header row and numbers of pairs, to stdout")
+	corrCmd.Flags().BoolP("cov", "c", false, "output covariances, or a covariance matrix for -w/--wide")
+	corrCmd.Flags().BoolP("pvalue", "p", false, "output two-sided p-values")
+	corrCmd.Flags().IntP("decimal-width", "n", 4, "limit floats to N decimal points")
 }
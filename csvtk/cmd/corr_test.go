@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestRankValues(t *testing.T) {
+	cases := []struct {
+		x      []float64
+		expect []float64
+	}{
+		{[]float64{}, []float64{}},
+		{[]float64{5}, []float64{1}},
+		{[]float64{3, 1, 2}, []float64{3, 1, 2}},
+		{[]float64{10, 20, 20, 30}, []float64{1, 2.5, 2.5, 4}},
+		{[]float64{2, 2, 2}, []float64{2, 2, 2}},
+		{[]float64{4, 1, 4, 1, 3}, []float64{4.5, 1.5, 4.5, 1.5, 3}},
+	}
+	for _, c := range cases {
+		if got := rankValues(c.x); !reflect.DeepEqual(got, c.expect) {
+			t.Errorf("rankValues(%v): want %v, got %v", c.x, c.expect, got)
+		}
+	}
+}
+
+// kendallTauBBruteForce computes Kendall's tau-b in O(n^2).
+func kendallTauBBruteForce(x, y []float64) float64 {
+	var con, dis, tx, ty float64
+	for i := range x {
+		for j := i + 1; j < len(x); j++ {
+			dx, dy := x[i]-x[j], y[i]-y[j]
+			switch {
+			case dx == 0 && dy == 0:
+			case dx == 0:
+				tx++
+			case dy == 0:
+				ty++
+			case dx*dy > 0:
+				con++
+			default:
+				dis++
+			}
+		}
+	}
+	return (con - dis) / math.Sqrt((con+dis+tx)*(con+dis+ty))
+}
+
+func TestKendallTauB(t *testing.T) {
+	cases := []struct {
+		x, y []float64
+		tau  float64 // expected value, NaN for computing with brute force
+		p    float64 // expected two-sided p-value, NaN for not checking
+	}{
+		{
+			x: []float64{1, 2, 3, 4, 5}, y: []float64{1, 2, 3, 4, 5},
+			tau: 1, p: math.NaN(),
+		},
+		{
+			x: []float64{1, 2, 3, 4, 5}, y: []float64{5, 4, 3, 2, 1},
+			tau: -1, p: math.NaN(),
+		},
+		// the example of scipy.stats.kendalltau
+		{
+			x: []float64{12, 2, 1, 12, 2}, y: []float64{1, 4, 7, 1, 0},
+			tau: -0.47140452079103173, p: 0.2827454599327748,
+		},
+		{
+			x:   []float64{1, 3, 2, 5, 4, 7, 6, 9, 8, 2, 3, 5},
+			y:   []float64{2, 1, 4, 3, 6, 5, 8, 7, 9, 9, 1, 1},
+			tau: math.NaN(), p: math.NaN(),
+		},
+		{
+			x:   []float64{1, 1, 1, 2, 2, 2, 3, 3, 3},
+			y:   []float64{3, 1, 2, 3, 3, 1, 2, 2, 2},
+			tau: math.NaN(), p: math.NaN(),
+		},
+	}
+
+	for _, c := range cases {
+		expect := c.tau
+		if math.IsNaN(expect) {
+			expect = kendallTauBBruteForce(c.x, c.y)
+		}
+		tau, _ := kendallTauB(c.x, c.y)
+		if math.Abs(tau-expect) > 1e-12 {
+			t.Errorf("kendallTauB(%v, %v): tau: want %v, got %v", c.x, c.y, expect, tau)
+		}
+		if math.IsNaN(c.p) {
+			continue
+		}
+		if _, p := correlation(c.x, c.y, "kendall", true); math.Abs(p-c.p) > 1e-9 {
+			t.Errorf("correlation(%v, %v, kendall): p-value: want %v, got %v", c.x, c.y, c.p, p)
+		}
+	}
+
+	if tau, _ := kendallTauB([]float64{1}, []float64{2}); !math.IsNaN(tau) {
+		t.Errorf("kendallTauB with one value: want NaN, got %v", tau)
+	}
+}
+
+func TestCorrelationNaN(t *testing.T) {
+	x := []float64{1, 2, math.NaN(), 4, 5}
+	y := []float64{2, 1, 4, 3, 5}
+	for _, method := range []string{"pearson", "spearman", "kendall"} {
+		if r, _ := correlation(x, y, method, true); !math.IsNaN(r) {
+			t.Errorf("correlation with NaN (%s): want NaN, got %v", method, r)
+		}
+
+		// pairwise deletion
+		x2, y2 := removeNaNs(x, y)
+		if len(x2) != 4 {
+			t.Errorf("removeNaNs: want 4 pairs, got %d", len(x2))
+		}
+		if r, _ := correlation(x2, y2, method, false); math.IsNaN(r) {
+			t.Errorf("correlation without NaN (%s): got NaN", method)
+		}
+	}
+
+	// spearman is pearson of ranks
+	r, _ := correlation([]float64{1, 2, 3, 4}, []float64{1, 4, 9, 100}, "spearman", false)
+	if r != 1 {
+		t.Errorf("correlation (spearman) of monotonic data: want 1, got %v", r)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -62,6 +63,60 @@ type CSVReader struct {
 	NumEmptyRows     []int // rows of emtpy rows
 	NumIllegalRows   []int // rows of illegal rows
 
+	// provenance columns, prepended to Record.All (and Record.Selected if ReadOption.ShowProvenance is true)
+	ShowFilename    bool // the source file
+	FilenameBase    bool // only keep the base name of the source file
+	FilenameTrimExt bool // trim the extension of the source file
+	ShowLineNumber  bool // the line number (Record.Line) in the source file
+}
+
+// NumProvenanceColumns returns the number of provenance columns prepended to Record.All.
+func (csvReader *CSVReader) NumProvenanceColumns() int {
+	var n int
+	if csvReader.ShowFilename {
+		n++
+	}
+	if csvReader.ShowLineNumber {
+		n++
+	}
+	return n
+}
+
+// provenanceFilename returns the file name shown in the provenance column.
+func (csvReader *CSVReader) provenanceFilename() string {
+	file := csvReader.file
+	if isStdin(file) {
+		return file
+	}
+	if csvReader.FilenameBase {
+		file = filepath.Base(file)
+	}
+	if csvReader.FilenameTrimExt {
+		dir, base := filepath.Split(file)
+		base, _, _ = filepathTrimExtension2(base, nil)
+		file = dir + base
+	}
+	return file
+}
+
+// provenanceValues returns values of provenance columns for a record.
+func (csvReader *CSVReader) provenanceValues(filename string, lineNum int, isHeaderRow bool) []string {
+	values := make([]string, 0, 2)
+	if csvReader.ShowFilename {
+		if isHeaderRow {
+			values = append(values, "file")
+		} else {
+			values = append(values, filename)
+		}
+	}
+	if csvReader.ShowLineNumber {
+		if isHeaderRow {
+			values = append(values, "line")
+		} else {
+			values = append(values, strconv.Itoa(lineNum))
+		}
+	}
+	return values
 }
 
 // NewCSVReader is
@@ -100,6 +155,7 @@ type ReadOption struct {
 	AllowMissingColumn             bool // allow missing column
 	BlankMissingColumn             bool
 	ShowRowNumber                  bool
+	ShowProvenance                 bool // also prepend provenance columns (file, line) to Record.Selected
 
 	Verbose bool
 }
@@ -124,6 +180,7 @@ func (csvReader *CSVReader) Read(opt ReadOption) {
 			allowMissingColumn = true
 		}
 		showRowNumber := opt.ShowRowNumber
+		showProvenance := opt.ShowProvenance
 		doNotAllowDuplicatedColumnName := opt.DoNotAllowDuplicatedColumnName
 
 		defer func() {
@@ -167,6 +224,15 @@ func (csvReader *CSVReader) Read(opt ReadOption) {
 		var err error
 		var isHeaderRow bool
 
+		// provenance columns
+		nProvenance := csvReader.NumProvenanceColumns()
+		var provenanceFilename string
+		if csvReader.ShowFilename {
+			provenanceFilename = csvReader.provenanceFilename()
+		}
+		var provenance []string
+		var fieldsShifted []int // fields shifted by the number of provenance columns
+
 		for {
 			record, err = csvReader.Reader.Read()
 			if err == io.EOF {
@@ -453,6 +519,13 @@ func (csvReader *CSVReader) Read(opt ReadOption) {
 				}
 			}
 
+			if nProvenance > 0 {
+				provenance = csvReader.provenanceValues(provenanceFilename, lineNum, isHeaderRow)
+				if showProvenance {
+					items = append(items, provenance...)
+				}
+			}
+
 			if allowMissingColumn {
 				for i, f = range fields {
 					if needParseHeaderRow { // using column
@@ -485,6 +558,24 @@ func (csvReader *CSVReader) Read(opt ReadOption) {
 					handleHeaderRow = false
 				}
 
+				if nProvenance > 0 {
+					if fieldsShifted == nil {
+						fieldsShifted = shiftFields(fields, nProvenance)
+					}
+					csvReader.Ch <- Record{
+						Line:     lineNum,
+						Row:      row,
+						All:      append(provenance, record...),
+						Fields:   fieldsShifted,
+						Selected: items,
+
+						IsHeaderRow:        isHeaderRow,
+						SelectWithColnames: selectWithColnames,
+					}
+
+					continue
+				}
+
 				csvReader.Ch <- Record{
 					Line:     lineNum,
 					Row:      row,
@@ -511,6 +602,24 @@ func (csvReader *CSVReader) Read(opt ReadOption) {
 				}
 			}
 
+			if nProvenance > 0 {
+				if fieldsShifted == nil {
+					fieldsShifted = shiftFields(fields, nProvenance)
+				}
+				csvReader.Ch <- Record{
+					Line:     lineNum,
+					Row:      row,
+					All:      append(provenance, record...),
+					Fields:   fieldsShifted,
+					Selected: items,
+
+					IsHeaderRow:        isHeaderRow,
+					SelectWithColnames: selectWithColnames,
+				}
+
+				continue
+			}
+
 			csvReader.Ch <- Record{
 				Line:     lineNum,
 				Row:      row,
@@ -527,6 +636,20 @@ func (csvReader *CSVReader) Read(opt ReadOption) {
 	}()
 }
 
+// shiftFields shifts positive fields by n, so they can still be used to
+// locate values in Record.All after prepending n provenance columns.
+func shiftFields(fields []int, n int) []int {
+	fields2 := make([]int, len(fields))
+	for i, f := range fields {
+		if f > 0 {
+			fields2[i] = f + n
+		} else {
+			fields2[i] = f
+		}
+	}
+	return fields2
+}
+
 func parseFields(
 	fieldsStr string,
 	fieldsStrSep string,
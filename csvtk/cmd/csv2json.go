@@ -91,7 +91,7 @@ var csv2jsonCmd = &cobra.Command{
 
 		keyed := fieldStr != ""
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
@@ -42,7 +42,7 @@ var csv2tabCmd = &cobra.Command{
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
 		runtime.GOMAXPROCS(config.NumCPUs)
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
@@ -64,8 +64,9 @@ var csv2tabCmd = &cobra.Command{
 			}
 
 			csvReader.Read(ReadOption{
-				FieldStr:      "1-",
-				ShowRowNumber: config.ShowRowNumber,
+				FieldStr:       "1-",
+				ShowRowNumber:  config.ShowRowNumber,
+				ShowProvenance: true,
 			})
 
 			for record := range csvReader.Ch {
@@ -87,8 +87,9 @@ Attention:
 			nSheets++
 
 			csvReader.Read(ReadOption{
-				FieldStr:      "1-",
-				ShowRowNumber: config.ShowRowNumber,
+				FieldStr:       "1-",
+				ShowRowNumber:  config.ShowRowNumber,
+				ShowProvenance: true,
 			})
 
 			if singleInput {
@@ -155,7 +156,10 @@ Attention:
 		}
 
 		xlsx.SetActiveSheet(firstIdx)
-		checkError(xlsx.SaveAs(outFile))
+		outfh, err := wopen(outFile, config.BackupSuffix)
+		checkError(err)
+		checkError(xlsx.Write(outfh))
+		checkError(outfh.Close())
 	},
 }
 
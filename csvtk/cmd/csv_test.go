@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestProvenanceColumns(t *testing.T) {
+	file := testFile(t, "a.csv", "id,name\n1,x\n2,y\n")
+	base := filepath.Base(file)
+
+	cases := []struct {
+		args   []string
+		expect string
+	}{
+		{
+			[]string{"cut", "-f", "2", "--show-filename", "--filename-base", "--show-line-number", file},
+			"file,line,name\n" + base + ",2,x\n" + base + ",3,y\n",
+		},
+		{
+			[]string{"cut", "-f", "2", "--show-filename", "--filename-base", "--filename-trim-ext", file},
+			"file,name\na,x\na,y\n",
+		},
+		{
+			[]string{"cut", "-H", "-f", "2", "--show-line-number", file},
+			"1,name\n2,x\n3,y\n",
+		},
+		// the first row is data for add-header
+		{
+			[]string{"add-header", "-n", "file,line,a,b", "--show-filename", "--filename-base", "--show-line-number", file},
+			"file,line,a,b\n" + base + ",1,id,name\n" + base + ",2,1,x\n" + base + ",3,2,y\n",
+		},
+	}
+	for _, c := range cases {
+		if got := runCsvtk(t, c.args...); got != c.expect {
+			t.Errorf("csvtk %v:\nwant:\n\t%q\ngot:\n\t%q\n", c.args, c.expect, got)
+		}
+	}
+}
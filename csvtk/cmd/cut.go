@@ -21,7 +21,6 @@
 package cmd
 
 import (
-	"encoding/csv"
 	"fmt"
 	"runtime"
 	"strings"
@@ -80,20 +79,11 @@ Examples:
 		allowMissingColumn := getFlagBool(cmd, "allow-missing-col")
 		blankMissingColumn := getFlagBool(cmd, "blank-missing-col")
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
-		writer := csv.NewWriter(outfh)
-		if config.OutTabs || config.Tabs {
-			if config.OutDelimiter == ',' { // default value, no other value given
-				writer.Comma = '\t'
-			} else {
-				writer.Comma = config.OutDelimiter
-			}
-		} else {
-			writer.Comma = config.OutDelimiter
-		}
+		writer := newRecordWriter(config, outfh)
 		defer func() {
 			writer.Flush()
 			checkError(writer.Error())
@@ -124,6 +114,7 @@ Examples:
 			AllowMissingColumn: allowMissingColumn,
 			BlankMissingColumn: blankMissingColumn,
 			ShowRowNumber:      config.ShowRowNumber,
+			ShowProvenance:     true,
 		})
 
 		handleHeaderRow := !config.NoHeaderRow
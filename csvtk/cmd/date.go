@@ -0,0 +1,399 @@
+// Copyright © 2016-2023 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/araddon/dateparse"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+	"gitlab.com/metakeule/fmtdate"
+)
+
+// dateCmd represents the date command
+var dateCmd = &cobra.Command{
+	GroupID: "edit",
+
+	Use:   "date",
+	Short: "date arithmetic and date-part extraction of selected fields",
+	Long: `date arithmetic and date-part extraction of selected fields
+
+Date parsing is supported by: https://github.com/araddon/dateparse
+Output format (--format) is in MS Excel (TM) syntax, the same as
+"csvtk fmtdate", type "csvtk fmtdate -h" for details.
+
+Operations (choose one):
+  --diff             difference between two dates (the 2nd field minus
+                     the 1st field) in the unit of -u/--unit, which is
+                     appended as a new column (-n/--name, default "diff").
+  -a/--add           add a duration, e.g., "1d", "-2w", "1y6M", "1h30m".
+                     Units: y (year), M (month), w (week), d (day),
+                            h (hour), m (minute), s (second).
+                     Overflowed days are normalized, e.g.,
+                     2024-01-31 + 1M = 2024-03-02.
+  -p/--part          extract a part of dates:
+                       year, quarter, month, day, hour, minute, second,
+                       weekday (1-7, Monday is 1), weekday-name,
+                       yearday, week (ISO 8601 week), isoyear,
+                       epoch (seconds since 1970-01-01 00:00:00 UTC)
+  --trunc            truncate dates to the start of a unit:
+                       year, quarter, month, week (Monday), day,
+                       hour, minute
+  --to-time-zone     convert dates to another time zone.
+
+  Results of -a/--add, --trunc and --to-time-zone are formatted
+  with --format.
+
+By default, values of selected fields are replaced with results, use
+-n/--name to append results as a new column, where only one field is
+allowed (two for --diff).
+
+Time zones:
+  -z/--time-zone is the time zone of input dates without zone information.
+  format: Asia/Shanghai
+  whole list: https://en.wikipedia.org/wiki/List_of_tz_database_time_zones
+
+Examples:
+
+  $ csvtk date -f start,end --diff -u hours data.csv
+  $ csvtk date -f date -p week -n week data.csv
+  $ csvtk date -f date --trunc month --format YYYY-MM data.csv
+  $ csvtk date -f time -z UTC --to-time-zone Asia/Shanghai data.csv
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if len(files) > 1 {
+			checkError(fmt.Errorf("no more than one file should be given"))
+		}
+		runtime.GOMAXPROCS(config.NumCPUs)
+
+		timezone := getFlagString(cmd, "time-zone")
+		outfmt := getFlagString(cmd, "format")
+		keepUnparsed := getFlagBool(cmd, "keep-unparsed")
+
+		if timezone != "" {
+			loc, err := time.LoadLocation(timezone)
+			if err != nil {
+				checkError(fmt.Errorf("setting time zone: %s", err))
+			}
+			time.Local = loc
+		}
+
+		diff := getFlagBool(cmd, "diff")
+		unit := getFlagString(cmd, "unit")
+		add := getFlagString(cmd, "add")
+		part := getFlagString(cmd, "part")
+		trunc := getFlagString(cmd, "trunc")
+		toTimezone := getFlagString(cmd, "to-time-zone")
+		name := getFlagString(cmd, "name")
+		decimalWidth := getFlagNonNegativeInt(cmd, "decimal-width")
+		decimalFormat := fmt.Sprintf("%%.%df", decimalWidth)
+
+		var nOps int
+		for _, ok := range []bool{diff, add != "", part != "", trunc != "", toTimezone != ""} {
+			if ok {
+				nOps++
+			}
+		}
+		if nOps != 1 {
+			checkError(fmt.Errorf("one and only one of --diff, -a/--add, -p/--part, --trunc and --to-time-zone should be given"))
+		}
+
+		var unitDuration time.Duration
+		if diff {
+			var ok bool
+			if unitDuration, ok = dateUnits[unit]; !ok {
+				checkError(fmt.Errorf("invalid unit: %s. available: seconds, minutes, hours, days, weeks", unit))
+			}
+			if name == "" {
+				name = "diff"
+			}
+		}
+
+		var years, months, days int
+		var duration time.Duration
+		if add != "" {
+			var err error
+			years, months, days, duration, err = parseDateDuration(add)
+			checkError(err)
+		}
+
+		if part != "" {
+			if _, ok := dateParts[part]; !ok {
+				checkError(fmt.Errorf("invalid date part: %s. run \"csvtk date -h\" for help", part))
+			}
+		}
+		if trunc != "" {
+			switch trunc {
+			case "year", "quarter", "month", "week", "day", "hour", "minute":
+			default:
+				checkError(fmt.Errorf("invalid unit for --trunc: %s. available: year, quarter, month, week, day, hour, minute", trunc))
+			}
+		}
+
+		var toLoc *time.Location
+		if toTimezone != "" {
+			var err error
+			toLoc, err = time.LoadLocation(toTimezone)
+			if err != nil {
+				checkError(fmt.Errorf("setting time zone: %s", err))
+			}
+		}
+
+		fieldStr := getFlagString(cmd, "fields")
+		if fieldStr == "" {
+			checkError(fmt.Errorf("flag -f (--fields) needed"))
+		}
+
+		fuzzyFields := getFlagBool(cmd, "fuzzy-fields")
+
+		outfh, err := wopenByConfig(config)
+		checkError(err)
+		defer outfh.Close()
+
+		writer := newRecordWriter(config, outfh)
+		defer func() {
+			writer.Flush()
+			checkError(writer.Error())
+		}()
+
+		file := files[0]
+		csvReader, err := newCSVReaderByConfig(config, file)
+
+		if err != nil {
+			if err == xopen.ErrNoContent {
+				if config.Verbose {
+					log.Warningf("csvtk date: skipping empty input file: %s", file)
+				}
+
+				writer.Flush()
+				checkError(writer.Error())
+				readerReport(&config, csvReader, file)
+				return
+			}
+			checkError(err)
+		}
+
+		csvReader.Read(ReadOption{
+			FieldStr:    fieldStr,
+			FuzzyFields: fuzzyFields,
+		})
+
+		// compute result of a value
+		compute := func(s string) (string, bool) {
+			t, err := dateparse.ParseLocal(s)
+			if err != nil {
+				return "", false
+			}
+			switch {
+			case add != "":
+				t = t.AddDate(years, months, days).Add(duration)
+			case part != "":
+				return dateParts[part](t), true
+			case trunc != "":
+				t = truncateDate(t, trunc)
+			case toLoc != nil:
+				t = t.In(toLoc)
+			}
+			return fmtdate.Format(outfmt, t), true
+		}
+
+		var result string
+		var ok bool
+		checkFirstLine := true
+		var f int
+		for record := range csvReader.Ch {
+			if record.Err != nil {
+				checkError(record.Err)
+			}
+
+			if checkFirstLine {
+				checkFirstLine = false
+
+				if diff && len(record.Fields) != 2 {
+					checkError(fmt.Errorf("two fields needed for --diff: %s", fieldStr))
+				}
+				if !diff && name != "" && len(record.Fields) != 1 {
+					checkError(fmt.Errorf("only one field is allowed when using -n/--name: %s", fieldStr))
+				}
+
+				if !config.NoHeaderRow || record.IsHeaderRow { // do not replace head line
+					if config.NoOutHeader {
+						continue
+					}
+					if name != "" {
+						checkError(writer.Write(append(record.All, name)))
+					} else {
+						checkError(writer.Write(record.All))
+					}
+					continue
+				}
+			}
+
+			if diff {
+				result = ""
+				t1, err1 := dateparse.ParseLocal(record.Selected[0])
+				t2, err2 := dateparse.ParseLocal(record.Selected[1])
+				if err1 == nil && err2 == nil {
+					result = fmt.Sprintf(decimalFormat, float64(t2.Sub(t1))/float64(unitDuration))
+				}
+				checkError(writer.Write(append(record.All, result)))
+				continue
+			}
+
+			if name != "" {
+				if result, ok = compute(record.Selected[0]); !ok && keepUnparsed {
+					result = record.Selected[0]
+				}
+				checkError(writer.Write(append(record.All, result)))
+				continue
+			}
+
+			for _, f = range record.Fields {
+				if result, ok = compute(record.All[f-1]); ok || !keepUnparsed {
+					record.All[f-1] = result
+				}
+			}
+			checkError(writer.Write(record.All))
+		}
+
+		readerReport(&config, csvReader, file)
+	},
+}
+
+var dateUnits = map[string]time.Duration{
+	"seconds": time.Second,
+	"minutes": time.Minute,
+	"hours":   time.Hour,
+	"days":    24 * time.Hour,
+	"weeks":   7 * 24 * time.Hour,
+}
+
+var dateParts = map[string]func(time.Time) string{
+	"year":    func(t time.Time) string { return strconv.Itoa(t.Year()) },
+	"quarter": func(t time.Time) string { return strconv.Itoa((int(t.Month())-1)/3 + 1) },
+	"month":   func(t time.Time) string { return strconv.Itoa(int(t.Month())) },
+	"day":     func(t time.Time) string { return strconv.Itoa(t.Day()) },
+	"hour":    func(t time.Time) string { return strconv.Itoa(t.Hour()) },
+	"minute":  func(t time.Time) string { return strconv.Itoa(t.Minute()) },
+	"second":  func(t time.Time) string { return strconv.Itoa(t.Second()) },
+	"weekday": func(t time.Time) string {
+		if t.Weekday() == time.Sunday {
+			return "7"
+		}
+		return strconv.Itoa(int(t.Weekday()))
+	},
+	"weekday-name": func(t time.Time) string { return t.Weekday().String() },
+	"yearday":      func(t time.Time) string { return strconv.Itoa(t.YearDay()) },
+	"week": func(t time.Time) string {
+		_, week := t.ISOWeek()
+		return strconv.Itoa(week)
+	},
+	"isoyear": func(t time.Time) string {
+		year, _ := t.ISOWeek()
+		return strconv.Itoa(year)
+	},
+	"epoch": func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) },
+}
+
+// truncateDate returns the start of the unit that t belongs to.
+func truncateDate(t time.Time, unit string) time.Time {
+	y, m, d := t.Date()
+	loc := t.Location()
+	switch unit {
+	case "year":
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc)
+	case "quarter":
+		return time.Date(y, (m-1)/3*3+1, 1, 0, 0, 0, 0, loc)
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	case "week":
+		offset := (int(t.Weekday()) + 6) % 7 // days since Monday
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, loc)
+	case "day":
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	case "hour":
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, loc)
+	case "minute":
+		return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, loc)
+	}
+	return t
+}
+
+var reDateDuration = regexp.MustCompile(`(\d+)([yMwdhms])`)
+var reDateDurationFull = regexp.MustCompile(`^[+-]?(\d+[yMwdhms])+$`)
+
+// parseDateDuration parses durations like "1y6M", "-2w" and "1h30m".
+func parseDateDuration(s string) (years, months, days int, duration time.Duration, err error) {
+	if !reDateDurationFull.MatchString(s) {
+		return 0, 0, 0, 0, fmt.Errorf("invalid duration: %s", s)
+	}
+	sign := 1
+	if s[0] == '-' {
+		sign = -1
+	}
+	var n int
+	for _, found := range reDateDuration.FindAllStringSubmatch(s, -1) {
+		n, _ = strconv.Atoi(found[1])
+		n *= sign
+		switch found[2] {
+		case "y":
+			years += n
+		case "M":
+			months += n
+		case "w":
+			days += 7 * n
+		case "d":
+			days += n
+		case "h":
+			duration += time.Duration(n) * time.Hour
+		case "m":
+			duration += time.Duration(n) * time.Minute
+		case "s":
+			duration += time.Duration(n) * time.Second
+		}
+	}
+	return years, months, days, duration, nil
+}
+
+func init() {
+	RootCmd.AddCommand(dateCmd)
+	dateCmd.Flags().StringP("fields", "f", "1", `select only these fields. e.g -f 1,2 or -f columnA,columnB`)
+	dateCmd.Flags().BoolP("fuzzy-fields", "F", false, `using fuzzy fields, e.g., -F -f "*name" or -F -f "id123*"`)
+	dateCmd.Flags().BoolP("diff", "", false, `compute the difference between two dates (the 2nd field minus the 1st field)`)
+	dateCmd.Flags().StringP("unit", "u", "days", `unit of differences for --diff: seconds, minutes, hours, days, weeks`)
+	dateCmd.Flags().StringP("add", "a", "", `add a duration, e.g., "1d", "-2w", "1y6M", "1h30m"`)
+	dateCmd.Flags().StringP("part", "p", "", `extract a part of dates, type "csvtk date -h" for details`)
+	dateCmd.Flags().StringP("trunc", "", "", `truncate dates to the start of a unit: year, quarter, month, week, day, hour, minute`)
+	dateCmd.Flags().StringP("to-time-zone", "", "", `convert dates to this time zone, e.g., "Asia/Shanghai"`)
+	dateCmd.Flags().StringP("name", "n", "", `append results as a new column with this name, instead of replacing values`)
+	dateCmd.Flags().StringP("format", "", "YYYY-MM-DD hh:mm:ss", `output date format in MS Excel (TM) syntax, type "csvtk fmtdate -h" for details`)
+	dateCmd.Flags().BoolP("keep-unparsed", "k", false, "keep unparsed values, instead of empty values")
+	dateCmd.Flags().StringP("time-zone", "z", "", `timezone aka "Asia/Shanghai" or "America/Los_Angeles" formatted time-zone of input dates, type "csvtk date -h" for details`)
+	dateCmd.Flags().IntP("decimal-width", "w", 2, "limit floats to N decimal points for --diff")
+}
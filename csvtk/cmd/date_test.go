@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateDuration(t *testing.T) {
+	cases := []struct {
+		s                   string
+		years, months, days int
+		duration            time.Duration
+		err                 bool
+	}{
+		{s: "1d", days: 1},
+		{s: "2w", days: 14},
+		{s: "1w2d", days: 9},
+		{s: "1y6M", years: 1, months: 6},
+		{s: "1h30m", duration: 90 * time.Minute},
+		{s: "45s", duration: 45 * time.Second},
+		{s: "1y2M3w4d5h6m7s", years: 1, months: 2, days: 25, duration: 5*time.Hour + 6*time.Minute + 7*time.Second},
+		{s: "+3d", days: 3},
+		{s: "-2w", days: -14},
+		{s: "-1y1d1h", years: -1, days: -1, duration: -time.Hour},
+		{s: "", err: true},
+		{s: "1", err: true},
+		{s: "d", err: true},
+		{s: "1x", err: true},
+		{s: "1d-2h", err: true},
+		{s: "1.5h", err: true},
+	}
+
+	for _, c := range cases {
+		years, months, days, duration, err := parseDateDuration(c.s)
+		if c.err {
+			if err == nil {
+				t.Errorf("parseDateDuration(%q): error expected", c.s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseDateDuration(%q): %s", c.s, err)
+			continue
+		}
+		if years != c.years || months != c.months || days != c.days || duration != c.duration {
+			t.Errorf("parseDateDuration(%q): want %d %d %d %s, got %d %d %d %s",
+				c.s, c.years, c.months, c.days, c.duration, years, months, days, duration)
+		}
+	}
+}
+
+func TestTruncateDate(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database not available: %s", err)
+	}
+
+	// Wednesday
+	d := time.Date(2024, 8, 14, 15, 4, 5, 6, time.UTC)
+	cases := []struct {
+		t      time.Time
+		unit   string
+		expect time.Time
+	}{
+		{d, "year", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{d, "quarter", time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{d, "month", time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)},
+		{d, "week", time.Date(2024, 8, 12, 0, 0, 0, 0, time.UTC)},
+		{d, "day", time.Date(2024, 8, 14, 0, 0, 0, 0, time.UTC)},
+		{d, "hour", time.Date(2024, 8, 14, 15, 0, 0, 0, time.UTC)},
+		{d, "minute", time.Date(2024, 8, 14, 15, 4, 0, 0, time.UTC)},
+		{d, "second", d},
+		// quarters
+		{time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), "quarter", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), "quarter", time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)},
+		// weeks start on Monday, across months and years
+		{time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC), "week", time.Date(2024, 8, 26, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 9, 2, 12, 0, 0, 0, time.UTC), "week", time.Date(2024, 9, 2, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), "week", time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC)},
+		// the time zone is kept, days are calendar days across DST
+		{time.Date(2024, 3, 10, 12, 0, 0, 0, loc), "day", time.Date(2024, 3, 10, 0, 0, 0, 0, loc)},
+		{time.Date(2024, 3, 12, 12, 0, 0, 0, loc), "week", time.Date(2024, 3, 11, 0, 0, 0, 0, loc)},
+		{time.Date(2024, 3, 10, 12, 0, 0, 0, loc), "week", time.Date(2024, 3, 4, 0, 0, 0, 0, loc)},
+	}
+
+	for _, c := range cases {
+		if got := truncateDate(c.t, c.unit); !got.Equal(c.expect) || got.Location() != c.expect.Location() {
+			t.Errorf("truncateDate(%s, %s): want %s, got %s", c.t, c.unit, c.expect, got)
+		}
+	}
+}
@@ -51,7 +51,7 @@ Limitation:
 		}
 		runtime.GOMAXPROCS(config.NumCPUs)
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
@@ -72,8 +72,9 @@ Limitation:
 		}
 
 		csvReader.Read(ReadOption{
-			FieldStr:      "1-",
-			ShowRowNumber: config.ShowRowNumber,
+			FieldStr:       "1-",
+			ShowRowNumber:  config.ShowRowNumber,
+			ShowProvenance: true,
 		})
 
 		d := string(config.Delimiter)
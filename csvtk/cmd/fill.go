@@ -0,0 +1,302 @@
+// Copyright © 2016-2023 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+	"gonum.org/v1/gonum/stat"
+)
+
+// fillCmd represents the fill command
+var fillCmd = &cobra.Command{
+	GroupID: "edit",
+
+	Use:   "fill",
+	Short: "fill or impute missing values of selected fields (groupby group fields)",
+	Long: `fill or impute missing values of selected fields (groupby group fields)
+
+Missing values are cells matching NA values (--na-values, case ignored).
+
+Methods (-m/--method):
+  down     fill with the last non-missing value above (forward fill)
+  up       fill with the next non-missing value below (backward fill)
+  value    fill with a constant value (-v/--value)
+  mean     impute with the mean of non-missing numeric values
+  median   impute with the median of non-missing numeric values
+
+With -g/--groups, values are filled within each group, e.g., the group
+mean is used for the method "mean".
+
+Attention:
+  1. Missing values at the beginning (for "down") or the end (for "up")
+     of a field (in each group) are kept unchanged, so are missing values
+     of fields without any numeric values for "mean" and "median".
+  2. For "mean" and "median", non-numeric values are not allowed unless
+     -i/--ignore-non-numbers is given, and they are not counted.
+  3. Fields of -g/--groups should not be open ranges (e.g., 3-) or
+     unselected fields, and -F/--fuzzy-fields is not supported along
+     with -g/--groups.
+  4. All records are kept in memory for methods "up", "mean" and "median".
+
+Examples:
+
+  # fill gaps of merged cells in spreadsheets
+  $ csvtk fill -f 1,2 data.csv
+
+  # impute with group means
+  $ csvtk fill -f score -m mean -g class data.csv
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if len(files) > 1 {
+			checkError(fmt.Errorf("no more than one file should be given"))
+		}
+		runtime.GOMAXPROCS(config.NumCPUs)
+
+		fieldStr := getFlagString(cmd, "fields")
+		if fieldStr == "" {
+			checkError(fmt.Errorf("flag -f (--fields) needed"))
+		}
+		fuzzyFields := getFlagBool(cmd, "fuzzy-fields")
+		groupsStr := getFlagString(cmd, "groups")
+		if fuzzyFields && groupsStr != "" {
+			checkError(fmt.Errorf("flag -F/--fuzzy-fields is not supported along with -g/--groups"))
+		}
+
+		method := getFlagString(cmd, "method")
+		var streaming bool
+		switch method {
+		case "down", "value":
+			streaming = true
+		case "up", "mean", "median":
+		default:
+			checkError(fmt.Errorf("invalid method: %s. available: down, up, value, mean, median", method))
+		}
+		value := getFlagString(cmd, "value")
+		if method == "value" && !cmd.Flags().Lookup("value").Changed {
+			checkError(fmt.Errorf("flag -v/--value needed for method: value"))
+		}
+
+		ignore := getFlagBool(cmd, "ignore-non-numbers")
+		naValues := make(map[string]struct{}, 8)
+		for _, v := range getFlagStringSlice(cmd, "na-values") {
+			naValues[strings.ToLower(v)] = struct{}{}
+		}
+		decimalWidth := getFlagNonNegativeInt(cmd, "decimal-width")
+		decimalFormat := fmt.Sprintf("%%.%df", decimalWidth)
+
+		var numFieldsG int
+		numFields := -1 // expected number of fields, unknown for open ranges of fields to fill
+		if groupsStr != "" {
+			numFieldsG = countFields(groupsStr, false)
+			if numFieldsG < 0 {
+				checkError(fmt.Errorf("open ranges or unselected fields are not supported for -g/--groups: %s", groupsStr))
+			}
+			fieldStr = groupsStr + "," + fieldStr
+			numFields = countFields(fieldStr, false)
+		}
+
+		outfh, err := wopenByConfig(config)
+		checkError(err)
+		defer outfh.Close()
+
+		writer := newRecordWriter(config, outfh)
+		defer func() {
+			writer.Flush()
+			checkError(writer.Error())
+		}()
+
+		file := files[0]
+		csvReader, err := newCSVReaderByConfig(config, file)
+
+		if err != nil {
+			if err == xopen.ErrNoContent {
+				if config.Verbose {
+					log.Warningf("csvtk fill: skipping empty input file: %s", file)
+				}
+
+				writer.Flush()
+				checkError(writer.Error())
+				readerReport(&config, csvReader, file)
+				return
+			}
+			checkError(err)
+		}
+
+		csvReader.Read(ReadOption{
+			FieldStr:    fieldStr,
+			FuzzyFields: fuzzyFields,
+		})
+
+		// data of a field in a group, keys are group keys and column indexes
+		type fillData struct {
+			last    string // last non-missing value, for "down"
+			hasLast bool
+			missing []int     // indexes of records with missing values
+			numbers []float64 // numeric values, for "mean" and "median"
+		}
+		data := make(map[string]*fillData, 64)
+		keys := make([]string, 0, 64)
+
+		var records [][]string
+
+		var group, key, s string
+		var v float64
+		var d *fillData
+		var ok bool
+		checkFirstLine := true
+		for record := range csvReader.Ch {
+			if record.Err != nil {
+				checkError(record.Err)
+			}
+
+			if checkFirstLine {
+				checkFirstLine = false
+				if numFields >= 0 && len(record.Fields) != numFields {
+					checkError(fmt.Errorf("the number of matched fields (%d) does not match that of given fields (%d), please check duplicated column names: %s", len(record.Fields), numFields, fieldStr))
+				}
+				if numFieldsG > 0 && len(record.Fields) <= numFieldsG {
+					checkError(fmt.Errorf("no fields to fill: %s", fieldStr))
+				}
+				if !config.NoHeaderRow || record.IsHeaderRow {
+					if !config.NoOutHeader {
+						checkError(writer.Write(record.All))
+					}
+					continue
+				}
+			}
+
+			if numFieldsG > 0 {
+				group = strings.Join(record.Selected[:numFieldsG], "_shenwei356_")
+			}
+
+			for _, f := range record.Fields[numFieldsG:] {
+				s = record.All[f-1]
+				_, ok = naValues[strings.ToLower(s)]
+
+				if method == "value" {
+					if ok {
+						record.All[f-1] = value
+					}
+					continue
+				}
+
+				key = group + "_shenwei356_" + strconv.Itoa(f)
+				if d, ok = data[key]; !ok {
+					d = &fillData{}
+					data[key] = d
+					keys = append(keys, key)
+				}
+
+				if _, ok = naValues[strings.ToLower(s)]; ok {
+					if method == "down" {
+						if d.hasLast {
+							record.All[f-1] = d.last
+						}
+					} else {
+						d.missing = append(d.missing, len(records))
+					}
+					continue
+				}
+
+				switch method {
+				case "down":
+					d.last, d.hasLast = s, true
+				case "up":
+					// fill previous missing values with this value
+					for _, r := range d.missing {
+						records[r][f-1] = s
+					}
+					d.missing = d.missing[:0]
+				default:
+					if !reDigitals.MatchString(s) {
+						if ignore {
+							continue
+						}
+						checkError(fmt.Errorf("[line %d] non-numeric value: %s, you can use flag -i/--ignore-non-numbers to skip these data", record.Line, s))
+					}
+					v, err = strconv.ParseFloat(removeComma(s), 64)
+					checkError(err)
+					d.numbers = append(d.numbers, v)
+				}
+			}
+
+			if streaming {
+				checkError(writer.Write(record.All))
+				continue
+			}
+			records = append(records, record.All)
+		}
+
+		readerReport(&config, csvReader, file)
+
+		if streaming {
+			return
+		}
+
+		if method == "mean" || method == "median" {
+			var f int
+			for _, key = range keys {
+				d = data[key]
+				if len(d.numbers) == 0 || len(d.missing) == 0 {
+					continue
+				}
+				f, _ = strconv.Atoi(key[strings.LastIndex(key, "_")+1:])
+
+				if method == "mean" {
+					v = stat.Mean(d.numbers, nil)
+				} else {
+					sort.Float64s(d.numbers)
+					v = median(d.numbers)
+				}
+				s = fmt.Sprintf(decimalFormat, v)
+				for _, r := range d.missing {
+					records[r][f-1] = s
+				}
+			}
+		}
+
+		for _, record := range records {
+			checkError(writer.Write(record))
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(fillCmd)
+	fillCmd.Flags().StringP("fields", "f", "1", `select only these fields. e.g -f 1,2 or -f columnA,columnB`)
+	fillCmd.Flags().BoolP("fuzzy-fields", "F", false, `using fuzzy fields, e.g., -F -f "*name" or -F -f "id123*"`)
+	fillCmd.Flags().StringP("groups", "g", "", `fill values within groups of these fields. e.g -g 1,2 or -g columnA,columnB`)
+	fillCmd.Flags().StringP("method", "m", "down", `method: down, up, value, mean, median`)
+	fillCmd.Flags().StringP("value", "v", "", `constant value for method "value"`)
+	fillCmd.Flags().StringSliceP("na-values", "", []string{"", "NA", "N/A"}, `NA values, case ignored`)
+	fillCmd.Flags().BoolP("ignore-non-numbers", "i", false, `ignore non-numeric values for methods "mean" and "median"`)
+	fillCmd.Flags().IntP("decimal-width", "n", 2, `limit floats to N decimal points for methods "mean" and "median"`)
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFill(t *testing.T) {
+	file := testFile(t, "scores.csv", `lab,member,score
+A,Tom,85
+,Ann,NA
+,Bob,62
+B,Kim,
+,Ray,91
+,Joe,58
+`)
+
+	cases := []struct {
+		args   []string
+		expect string
+	}{
+		{
+			[]string{"-f", "lab"},
+			"lab,member,score\nA,Tom,85\nA,Ann,NA\nA,Bob,62\nB,Kim,\nB,Ray,91\nB,Joe,58\n",
+		},
+		{
+			[]string{"-f", "lab,score", "-m", "down"},
+			"lab,member,score\nA,Tom,85\nA,Ann,85\nA,Bob,62\nB,Kim,62\nB,Ray,91\nB,Joe,58\n",
+		},
+		{
+			[]string{"-f", "score", "-m", "up"},
+			"lab,member,score\nA,Tom,85\n,Ann,62\n,Bob,62\nB,Kim,91\n,Ray,91\n,Joe,58\n",
+		},
+		// missing values at the end of a group are kept
+		{
+			[]string{"-f", "score", "-m", "up", "-g", "lab"},
+			"lab,member,score\nA,Tom,85\n,Ann,62\n,Bob,62\nB,Kim,\n,Ray,91\n,Joe,58\n",
+		},
+		{
+			[]string{"-F", "-f", "*e", "-m", "value", "-v", "0"},
+			"lab,member,score\nA,Tom,85\n,Ann,0\n,Bob,62\nB,Kim,0\n,Ray,91\n,Joe,58\n",
+		},
+		{
+			[]string{"-f", "score", "-m", "mean", "-n", "1"},
+			"lab,member,score\nA,Tom,85\n,Ann,74.0\n,Bob,62\nB,Kim,74.0\n,Ray,91\n,Joe,58\n",
+		},
+		// other NA values, and ignoring non-numeric values
+		{
+			[]string{"-f", "score", "-m", "median", "--na-values", "na", "-i"},
+			"lab,member,score\nA,Tom,85\n,Ann,73.50\n,Bob,62\nB,Kim,\n,Ray,91\n,Joe,58\n",
+		},
+		// fields without any numeric values are kept
+		{
+			[]string{"-f", "member,score", "-m", "mean", "-i"},
+			"lab,member,score\nA,Tom,85\n,Ann,74.00\n,Bob,62\nB,Kim,74.00\n,Ray,91\n,Joe,58\n",
+		},
+	}
+	for _, c := range cases {
+		got := runCsvtk(t, append([]string{"fill", file}, c.args...)...)
+		if got != c.expect {
+			t.Errorf("csvtk fill %s:\nwant %q\ngot  %q", strings.Join(c.args, " "), c.expect, got)
+		}
+	}
+
+	// group medians
+	filled := testFile(t, "filled.csv", runCsvtk(t, "fill", file, "-f", "lab"))
+	got := runCsvtk(t, "fill", filled, "-f", "score", "-m", "median", "-g", "lab")
+	if expect := "lab,member,score\nA,Tom,85\nA,Ann,73.50\nA,Bob,62\nB,Kim,74.50\nB,Ray,91\nB,Joe,58\n"; got != expect {
+		t.Errorf("csvtk fill -m median -g lab:\nwant %q\ngot  %q", expect, got)
+	}
+
+	// errors
+	for _, args := range [][]string{
+		{"-f", "member", "-m", "mean"},              // non-numeric values
+		{"-f", "score", "-m", "value"},              // no value
+		{"-f", "score", "-m", "max"},                // invalid method
+		{"-F", "-f", "*e", "-m", "up", "-g", "lab"}, // fuzzy fields with groups
+	} {
+		if _, err := execCsvtkInSubprocess(t, append([]string{"fill", file}, args...)...); err == nil {
+			t.Errorf("csvtk fill %s: want error, got nil", strings.Join(args, " "))
+		}
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright © 2016-2023 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+	"gonum.org/v1/gonum/stat"
+)
+
+// fitCmd represents the fit command
+var fitCmd = &cobra.Command{
+	GroupID: "info",
+
+	Use:   "fit",
+	Short: "simple linear regression of two columns (groupby group fields)",
+	Long: `simple linear regression of two columns (groupby group fields)
+
+The model y = intercept + slope * x is fitted with ordinary least squares
+for each group.
+
+Output columns:
+  [groups], n, slope, intercept, r_squared, slope_se, intercept_se,
+  slope_pvalue, residual_se, residual_min, residual_median, residual_max
+
+  slope_pvalue: two-sided p-value of the t-test of slope = 0.
+  residual_se:  residual standard error, sqrt(RSS / (n - 2)).
+
+With -a/--append, the input records are outputted with two extra columns
+of fitted values and residuals, instead of fitted models. Cells are
+empty for non-numeric values.
+
+Attention:
+  1. Records with non-numeric values are not allowed unless
+     -i/--ignore-non-numbers is given.
+  2. Fields of -g/--groups should not be ranges or fuzzy fields.
+  3. Groups are outputted in the order of first appearance.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if len(files) > 1 {
+			checkError(fmt.Errorf("no more than one file should be given"))
+		}
+		runtime.GOMAXPROCS(config.NumCPUs)
+
+		fieldX := getFlagString(cmd, "x")
+		fieldY := getFlagString(cmd, "y")
+		if fieldX == "" || fieldY == "" {
+			checkError(fmt.Errorf("flags -x and -y needed"))
+		}
+		if strings.Contains(fieldX, ",") || strings.Contains(fieldY, ",") {
+			checkError(fmt.Errorf("only one field is allowed for -x and -y"))
+		}
+		groupsStr := getFlagString(cmd, "groups")
+		appendMode := getFlagBool(cmd, "append")
+		ignore := getFlagBool(cmd, "ignore-non-numbers")
+		decimalWidth := getFlagNonNegativeInt(cmd, "decimal-width")
+		decimalFormat := fmt.Sprintf("%%.%df", decimalWidth)
+
+		fieldStr := fieldX + "," + fieldY
+		var numFieldsG int
+		if groupsStr != "" {
+			fieldStr += "," + groupsStr
+			numFieldsG = len(strings.Split(groupsStr, ","))
+		}
+
+		outfh, err := wopenByConfig(config)
+		checkError(err)
+		defer outfh.Close()
+
+		writer := newRecordWriter(config, outfh)
+		defer func() {
+			writer.Flush()
+			checkError(writer.Error())
+		}()
+
+		file := files[0]
+		csvReader, err := newCSVReaderByConfig(config, file)
+
+		if err != nil {
+			if err == xopen.ErrNoContent {
+				if config.Verbose {
+					log.Warningf("csvtk fit: skipping empty input file: %s", file)
+				}
+
+				writer.Flush()
+				checkError(writer.Error())
+				readerReport(&config, csvReader, file)
+				return
+			}
+			checkError(err)
+		}
+
+		csvReader.Read(ReadOption{
+			FieldStr: fieldStr,
+
+			DoNotAllowDuplicatedColumnName: true,
+		})
+
+		parse := func(s string, line int) (float64, bool) {
+			if !reDigitals.MatchString(s) {
+				if ignore {
+					return 0, false
+				}
+				checkError(fmt.Errorf("[line %d] non-numeric value: %s, you can use flag -i/--ignore-non-numbers to skip these data", line, s))
+			}
+			v, err := strconv.ParseFloat(removeComma(s), 64)
+			checkError(err)
+			return v, true
+		}
+
+		type fitRecord struct {
+			record []string
+			group  string
+			x, y   float64
+			okX    bool
+			okY    bool
+		}
+		var records []fitRecord // for -a/--append
+
+		xs := make(map[string][]float64, 8)
+		ys := make(map[string][]float64, 8)
+		groups := make([]string, 0, 8)
+
+		var HeaderRow []string
+		var group string
+		var x, y float64
+		var okX, okY, ok bool
+		checkFirstLine := true
+		for record := range csvReader.Ch {
+			if record.Err != nil {
+				checkError(record.Err)
+			}
+
+			if checkFirstLine {
+				checkFirstLine = false
+				if len(record.Fields) != 2+numFieldsG {
+					checkError(fmt.Errorf("fields should not be ranges or fuzzy fields: %s", fieldStr))
+				}
+				if !config.NoHeaderRow || record.IsHeaderRow {
+					if appendMode {
+						HeaderRow = append(record.All, "fitted", "residual")
+					} else {
+						HeaderRow = append([]string{}, record.Selected[2:]...)
+					}
+					continue
+				}
+			}
+
+			group = strings.Join(record.Selected[2:], "_shenwei356_")
+			if _, ok = xs[group]; !ok {
+				xs[group] = make([]float64, 0, 1024)
+				ys[group] = make([]float64, 0, 1024)
+				groups = append(groups, group)
+			}
+
+			x, okX = parse(record.Selected[0], record.Line)
+			y, okY = parse(record.Selected[1], record.Line)
+			if okX && okY {
+				xs[group] = append(xs[group], x)
+				ys[group] = append(ys[group], y)
+			}
+
+			if appendMode {
+				records = append(records, fitRecord{record: record.All, group: group, x: x, y: y, okX: okX, okY: okY})
+			}
+		}
+
+		readerReport(&config, csvReader, file)
+
+		models := make(map[string]*linearModel, len(groups))
+		for _, group = range groups {
+			models[group] = linearFit(xs[group], ys[group])
+		}
+
+		format := func(v float64) string { return fmt.Sprintf(decimalFormat, v) }
+
+		if appendMode {
+			if HeaderRow != nil && !config.NoOutHeader {
+				checkError(writer.Write(HeaderRow))
+			}
+			var m *linearModel
+			var fitted string
+			for _, r := range records {
+				m = models[r.group]
+				fitted = ""
+				if r.okX && m.n >= 2 {
+					fitted = format(m.intercept + m.slope*r.x)
+				}
+				if r.okX && r.okY && m.n >= 2 {
+					checkError(writer.Write(append(r.record, fitted, format(r.y-m.intercept-m.slope*r.x))))
+				} else {
+					checkError(writer.Write(append(r.record, fitted, "")))
+				}
+			}
+			return
+		}
+
+		if !config.NoOutHeader {
+			if HeaderRow == nil {
+				HeaderRow = make([]string, numFieldsG)
+			}
+			checkError(writer.Write(append(HeaderRow, "n", "slope", "intercept", "r_squared",
+				"slope_se", "intercept_se", "slope_pvalue",
+				"residual_se", "residual_min", "residual_median", "residual_max")))
+		}
+		var items []string
+		for _, group = range groups {
+			m := models[group]
+			items = make([]string, 0, numFieldsG+11)
+			if numFieldsG > 0 {
+				items = append(items, strings.Split(group, "_shenwei356_")...)
+			}
+			items = append(items, strconv.Itoa(m.n),
+				format(m.slope), format(m.intercept), format(m.rSquared),
+				format(m.slopeSE), format(m.interceptSE), strconv.FormatFloat(m.slopePvalue, 'g', 4, 64),
+				format(m.residualSE), format(m.residualMin), format(m.residualMedian), format(m.residualMax))
+			checkError(writer.Write(items))
+		}
+	},
+}
+
+// linearModel is a fitted simple linear regression model.
+type linearModel struct {
+	n              int
+	slope          float64
+	intercept      float64
+	rSquared       float64
+	slopeSE        float64
+	interceptSE    float64
+	slopePvalue    float64
+	residualSE     float64
+	residualMin    float64
+	residualMax    float64
+	residualMedian float64
+}
+
+// linearFit fits y = intercept + slope * x with ordinary least squares.
+func linearFit(x, y []float64) *linearModel {
+	m := &linearModel{n: len(x)}
+	nan := math.NaN()
+	if m.n < 2 {
+		m.slope, m.intercept, m.rSquared = nan, nan, nan
+		m.slopeSE, m.interceptSE, m.slopePvalue = nan, nan, nan
+		m.residualSE, m.residualMin, m.residualMedian, m.residualMax = nan, nan, nan, nan
+		return m
+	}
+
+	m.intercept, m.slope = stat.LinearRegression(x, y, nil, false)
+	m.rSquared = stat.RSquared(x, y, nil, m.intercept, m.slope)
+
+	residuals := make([]float64, len(x))
+	var rss float64
+	for i := range x {
+		residuals[i] = y[i] - m.intercept - m.slope*x[i]
+		rss += residuals[i] * residuals[i]
+	}
+	sort.Float64s(residuals)
+	m.residualMin = residuals[0]
+	m.residualMax = residuals[len(residuals)-1]
+	m.residualMedian = median(residuals)
+
+	n := float64(m.n)
+	meanX := stat.Mean(x, nil)
+	var sxx float64
+	for _, v := range x {
+		sxx += (v - meanX) * (v - meanX)
+	}
+	if m.n > 2 {
+		m.residualSE = math.Sqrt(rss / (n - 2))
+		m.slopeSE = m.residualSE / math.Sqrt(sxx)
+		m.interceptSE = m.residualSE * math.Sqrt(1/n+meanX*meanX/sxx)
+		m.slopePvalue = pValueT(m.slope/m.slopeSE, n-2, "two-sided")
+	} else {
+		m.residualSE, m.slopeSE, m.interceptSE, m.slopePvalue = nan, nan, nan, nan
+	}
+	return m
+}
+
+func init() {
+	RootCmd.AddCommand(fitCmd)
+
+	fitCmd.Flags().StringP("x", "x", "", `field of the independent variable. e.g -x 1 or -x columnA`)
+	fitCmd.Flags().StringP("y", "y", "", `field of the dependent variable. e.g -y 2 or -y columnB`)
+	fitCmd.Flags().StringP("groups", "g", "", `fit a model for each group of these fields. e.g -g 3,4 or -g columnC,columnD`)
+	fitCmd.Flags().BoolP("append", "a", false, `append columns of fitted values and residuals to input records`)
+	fitCmd.Flags().BoolP("ignore-non-numbers", "i", false, `ignore records with non-numeric values like "NA" or "N/A"`)
+	fitCmd.Flags().IntP("decimal-width", "w", 4, "limit floats to N decimal points")
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLinearFit(t *testing.T) {
+	nan := math.NaN()
+	cases := []struct {
+		x, y   []float64
+		expect linearModel
+	}{
+		{
+			x: []float64{1, 2, 3, 4, 5},
+			y: []float64{2, 4, 5, 4, 5},
+			expect: linearModel{n: 5, slope: 0.6, intercept: 2.2, rSquared: 0.6,
+				slopeSE: 0.282842712474619, interceptSE: 0.938083151964686, slopePvalue: 0.12402706265755459,
+				residualSE: 0.8944271909999159, residualMin: -0.8, residualMedian: -0.2, residualMax: 1},
+		},
+		// a perfect fit
+		{
+			x: []float64{0, 1, 2, 3},
+			y: []float64{1, 3, 5, 7},
+			expect: linearModel{n: 4, slope: 2, intercept: 1, rSquared: 1,
+				slopeSE: 0, interceptSE: 0, slopePvalue: 0,
+				residualSE: 0, residualMin: 0, residualMedian: 0, residualMax: 0},
+		},
+		// two points, standard errors are not available
+		{
+			x: []float64{1, 3},
+			y: []float64{1, 5},
+			expect: linearModel{n: 2, slope: 2, intercept: -1, rSquared: 1,
+				slopeSE: nan, interceptSE: nan, slopePvalue: nan,
+				residualSE: nan, residualMin: 0, residualMedian: 0, residualMax: 0},
+		},
+		// too few points
+		{
+			x: []float64{1},
+			y: []float64{1},
+			expect: linearModel{n: 1, slope: nan, intercept: nan, rSquared: nan,
+				slopeSE: nan, interceptSE: nan, slopePvalue: nan,
+				residualSE: nan, residualMin: nan, residualMedian: nan, residualMax: nan},
+		},
+	}
+
+	equal := func(a, b float64) bool {
+		if math.IsNaN(a) || math.IsNaN(b) {
+			return math.IsNaN(a) && math.IsNaN(b)
+		}
+		return math.Abs(a-b) < 1e-9
+	}
+	for _, c := range cases {
+		m := linearFit(c.x, c.y)
+		e := c.expect
+		if m.n != e.n || !equal(m.slope, e.slope) || !equal(m.intercept, e.intercept) || !equal(m.rSquared, e.rSquared) ||
+			!equal(m.slopeSE, e.slopeSE) || !equal(m.interceptSE, e.interceptSE) || !equal(m.slopePvalue, e.slopePvalue) ||
+			!equal(m.residualSE, e.residualSE) || !equal(m.residualMin, e.residualMin) ||
+			!equal(m.residualMedian, e.residualMedian) || !equal(m.residualMax, e.residualMax) {
+			t.Errorf("linearFit(%v, %v):\nwant %+v\ngot  %+v", c.x, c.y, e, *m)
+		}
+	}
+
+	// the slope is not finite if all X values are the same
+	m := linearFit([]float64{2, 2, 2}, []float64{1, 2, 3})
+	if !math.IsNaN(m.slope) && !math.IsInf(m.slope, 0) {
+		t.Errorf("linearFit with the same X values: want a non-finite slope, got %v", m.slope)
+	}
+}
+
+func TestFit(t *testing.T) {
+	file := testFile(t, "data.csv", `g,x,y
+a,1,2
+b,0,1
+a,2,4
+b,1,3
+a,3,5
+b,2,5
+a,4,4
+b,3,7
+a,5,5
+b,4,NA
+`)
+
+	cases := []struct {
+		args   []string
+		expect string
+	}{
+		{
+			[]string{"fit", "-x", "x", "-y", "y", "-g", "g", "-i", "-w", "2", file},
+			`g,n,slope,intercept,r_squared,slope_se,intercept_se,slope_pvalue,residual_se,residual_min,residual_median,residual_max
+a,5,0.60,2.20,0.60,0.28,0.94,0.124,0.89,-0.80,-0.20,1.00
+b,4,2.00,1.00,1.00,0.00,0.00,0,0.00,0.00,0.00,0.00
+`,
+		},
+		{
+			[]string{"fit", "-x", "2", "-y", "3", "-g", "1", "-i", "-w", "1", "-a", file},
+			`g,x,y,fitted,residual
+a,1,2,2.8,-0.8
+b,0,1,1.0,0.0
+a,2,4,3.4,0.6
+b,1,3,3.0,0.0
+a,3,5,4.0,1.0
+b,2,5,5.0,0.0
+a,4,4,4.6,-0.6
+b,3,7,7.0,0.0
+a,5,5,5.2,-0.2
+b,4,NA,9.0,
+`,
+		},
+	}
+	for _, c := range cases {
+		if got := runCsvtk(t, c.args...); got != c.expect {
+			t.Errorf("csvtk %v:\nwant:\n%s\ngot:\n%s", c.args, c.expect, got)
+		}
+	}
+
+	// the fit line of a group with the same X values is skipped in plot line
+	file = testFile(t, "line.csv", "x,y,g\n1,1,a\n1,2,a\n1,3,a\n1,1,b\n2,2,b\n")
+	args := []string{"plot", "line", "--fit-line", "-x", "x", "-y", "y", "-g", "g", file}
+	if got := runCsvtkWithOutFile(t, filepath.Join(t.TempDir(), "line.png"), args...); !strings.HasPrefix(got, "\x89PNG") {
+		t.Errorf("csvtk %v: a PNG file expected", args)
+	}
+}
@@ -85,7 +85,7 @@ Limitation:
 		}
 		_buf := make([]byte, bufferSize)
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
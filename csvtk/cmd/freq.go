@@ -21,14 +21,13 @@
 package cmd
 
 import (
-	"encoding/csv"
 	"fmt"
+	"math"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 
-	"github.com/shenwei356/util/stringutil"
 	"github.com/shenwei356/xopen"
 	"github.com/spf13/cobra"
 )
@@ -41,6 +40,35 @@ var freqCmd = &cobra.Command{
 	Short: "frequencies of selected fields",
 	Long: `frequencies of selected fields
 
+Extra columns:
+  -p/--proportion    proportion of the frequency.
+  -c/--cumulative    cumulative frequency, and cumulative proportion if -p given.
+                     Values are accumulated in the output order.
+
+Attention:
+  1. With -W/--weight-field, values of the field are summed up as the
+     frequency, instead of counting records.
+  2. With -g/--groups, a frequency table is computed for each group,
+     and proportions are computed within each group.
+     Groups are outputted in the order of their first appearance.
+  3. With --top N, only the first N keys are outputted, and the remaining
+     ones are merged into a record with the keys of --other-label.
+     Records are sorted by frequency in descending order if neither
+     -n/--sort-by-freq nor -k/--sort-by-key is given.
+  4. Fields of -g/--groups and -W/--weight-field should not be open ranges
+     (e.g., 3-) or unselected fields, and -F/--fuzzy-fields is not supported
+     along with them.
+
+Examples:
+  1. Top 3 keys with proportions and cumulative proportions.
+
+      $ csvtk freq -f 1 --top 3 -p -c data.csv
+
+  2. Frequency of column b in every group of column a,
+     weighted by column c.
+
+      $ csvtk freq -f b -g a -W c -p data.csv
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		config := getConfigs(cmd)
@@ -61,27 +89,76 @@ var freqCmd = &cobra.Command{
 
 		fuzzyFields := getFlagBool(cmd, "fuzzy-fields")
 
-		outfh, err := xopen.Wopen(config.OutFile)
-		checkError(err)
-		defer outfh.Close()
+		addProportion := getFlagBool(cmd, "proportion")
+		addCumulative := getFlagBool(cmd, "cumulative")
+		percent := getFlagBool(cmd, "percent")
+		if percent && !addProportion {
+			checkError(fmt.Errorf("flag --percent only works with -p/--proportion"))
+		}
+		decimalWidth := getFlagNonNegativeInt(cmd, "decimal-width")
+		decimalFormat := fmt.Sprintf("%%.%df", decimalWidth)
+		top := getFlagNonNegativeInt(cmd, "top")
+		otherLabel := getFlagString(cmd, "other-label")
+		if top > 0 && !sortByFreq && !sortByKey {
+			sortByFreq = true
+			reverse = true
+		}
 
-		writer := csv.NewWriter(outfh)
-		if config.OutTabs || config.Tabs {
-			if config.OutDelimiter == ',' {
-				writer.Comma = '\t'
-			} else {
-				writer.Comma = config.OutDelimiter
+		weightField := getFlagString(cmd, "weight-field")
+		groupsStr := getFlagString(cmd, "groups")
+
+		// fields to read: groups, weight, keys
+		var fieldsStrsE []string
+		var numFieldsG, numFieldsW int
+		if (groupsStr != "" || weightField != "") && fuzzyFields {
+			checkError(fmt.Errorf("flag -F/--fuzzy-fields is not supported along with -g/--groups or -W/--weight-field"))
+		}
+		if groupsStr != "" {
+			numFieldsG = countFields(groupsStr, false)
+			if numFieldsG < 0 {
+				checkError(fmt.Errorf("open ranges or unselected fields are not supported for -g/--groups: %s", groupsStr))
 			}
-		} else {
-			writer.Comma = config.OutDelimiter
+			fieldsStrsE = append(fieldsStrsE, groupsStr)
 		}
+		if weightField != "" {
+			if countFields(weightField, false) != 1 {
+				checkError(fmt.Errorf("only one field is allowed for -W/--weight-field"))
+			}
+			fieldsStrsE = append(fieldsStrsE, weightField)
+			numFieldsW = 1
+		}
+		numFieldsE := numFieldsG + numFieldsW
+		numFields := -1 // expected number of fields, unknown for open ranges of key fields
+		if numFieldsE > 0 {
+			fieldStr = strings.Join(fieldsStrsE, ",") + "," + fieldStr
+			numFields = countFields(fieldStr, false)
+		}
+
+		formatFreq := func(v float64) string {
+			if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+				return strconv.FormatFloat(v, 'f', 0, 64)
+			}
+			return fmt.Sprintf(decimalFormat, v)
+		}
+		formatProportion := func(v float64) string {
+			if percent {
+				v *= 100
+			}
+			return fmt.Sprintf(decimalFormat, v)
+		}
+
+		outfh, err := wopenByConfig(config)
+		checkError(err)
+		defer outfh.Close()
+
+		writer := newRecordWriter(config, outfh)
 		defer func() {
 			writer.Flush()
 			checkError(writer.Error())
 		}()
 
-		counter := make(map[string]int, 10000)
-		orders := make(map[string]int, 10000)
+		tables := make(map[string]*freqTable, 8)
+		groups := make([]string, 0, 8)
 
 		file := files[0]
 		csvReader, err := newCSVReaderByConfig(config, file)
@@ -107,7 +184,11 @@ var freqCmd = &cobra.Command{
 			DoNotAllowDuplicatedColumnName: true,
 		})
 
-		var key string
+		var key, group string
+		var table *freqTable
+		var item *freqItem
+		var ok bool
+		var weight float64
 		var N int
 
 		checkFirstLine := true
@@ -118,64 +199,130 @@ var freqCmd = &cobra.Command{
 
 			if checkFirstLine {
 				checkFirstLine = false
+				if numFields >= 0 && len(record.Fields) != numFields {
+					checkError(fmt.Errorf("the number of matched fields (%d) does not match that of given fields (%d), please check duplicated column names: %s", len(record.Fields), numFields, fieldStr))
+				}
+				if len(record.Selected) <= numFieldsE {
+					checkError(fmt.Errorf("no key fields selected"))
+				}
 				if !config.NoHeaderRow || record.IsHeaderRow {
 					if config.NoOutHeader {
 						continue
 					}
-					checkError(writer.Write(append(record.Selected, "frequency")))
+					header := make([]string, 0, len(record.Selected)+3)
+					header = append(header, record.Selected[:numFieldsG]...)
+					header = append(header, record.Selected[numFieldsE:]...)
+					header = append(header, "frequency")
+					if addProportion {
+						header = append(header, "proportion")
+					}
+					if addCumulative {
+						header = append(header, "cumulative_frequency")
+						if addProportion {
+							header = append(header, "cumulative_proportion")
+						}
+					}
+					checkError(writer.Write(header))
 					continue
 				}
 			}
 
 			N++
 
-			key = strings.Join(record.Selected, "_shenwei356_")
-			counter[key]++
-			orders[key] = N
-		}
+			weight = 1
+			if numFieldsW > 0 {
+				weight, err = strconv.ParseFloat(strings.TrimSpace(record.Selected[numFieldsG]), 64)
+				if err != nil {
+					checkError(fmt.Errorf("[line %d] failed to parse weight value: %s", record.Line, record.Selected[numFieldsG]))
+				}
+			}
 
-		var items []string
-		if sortByFreq {
-			counts := make([]stringutil.StringCount, len(counter))
-			i := 0
-			for key, count := range counter {
-				counts[i] = stringutil.StringCount{Key: key, Count: count}
-				i++
+			group = strings.Join(record.Selected[:numFieldsG], "_shenwei356_")
+			if table, ok = tables[group]; !ok {
+				table = &freqTable{counter: make(map[string]*freqItem, 1024)}
+				tables[group] = table
+				groups = append(groups, group)
 			}
-			if reverse {
-				sort.Sort(stringutil.ReversedStringCountList{counts})
-			} else {
-				sort.Sort(stringutil.StringCountList(counts))
+
+			key = strings.Join(record.Selected[numFieldsE:], "_shenwei356_")
+			if item, ok = table.counter[key]; !ok {
+				item = &freqItem{key: key}
+				table.counter[key] = item
 			}
-			for _, count := range counts {
-				items = strings.Split(count.Key, "_shenwei356_")
-				items = append(items, strconv.Itoa(counter[count.Key]))
-				checkError(writer.Write(items))
+			item.count += weight
+			item.order = N
+			table.total += weight
+		}
+
+		var items []*freqItem
+		var row []string
+		var cumulative float64
+		for _, group = range groups {
+			table = tables[group]
+
+			items = items[:0]
+			for _, item = range table.counter {
+				items = append(items, item)
 			}
-		} else if sortByKey {
-			keys := make([]string, len(counter))
-			i := 0
-			for key := range counter {
-				keys[i] = key
-				i++
+			if sortByFreq {
+				if reverse {
+					sort.Slice(items, func(i, j int) bool {
+						if items[i].count == items[j].count {
+							return items[i].key < items[j].key
+						}
+						return items[i].count > items[j].count
+					})
+				} else {
+					sort.Slice(items, func(i, j int) bool {
+						if items[i].count == items[j].count {
+							return items[i].key < items[j].key
+						}
+						return items[i].count < items[j].count
+					})
+				}
+			} else if sortByKey {
+				if reverse {
+					sort.Slice(items, func(i, j int) bool { return items[i].key > items[j].key })
+				} else {
+					sort.Slice(items, func(i, j int) bool { return items[i].key < items[j].key })
+				}
+			} else {
+				sort.Slice(items, func(i, j int) bool { return items[i].order < items[j].order })
 			}
 
-			sort.Strings(keys)
-			if reverse {
-				stringutil.ReverseStringSliceInplace(keys)
+			if top > 0 && len(items) > top {
+				other := &freqItem{}
+				for _, item = range items[top:] {
+					other.count += item.count
+				}
+				nKeys := len(strings.Split(items[0].key, "_shenwei356_"))
+				labels := make([]string, nKeys)
+				for i := range labels {
+					labels[i] = otherLabel
+				}
+				other.key = strings.Join(labels, "_shenwei356_")
+				items = append(items[:top], other)
 			}
 
-			for _, key := range keys {
-				items = strings.Split(key, "_shenwei356_")
-				items = append(items, strconv.Itoa(counter[key]))
-				checkError(writer.Write(items))
-			}
-		} else {
-			orderedKey := stringutil.SortCountOfString(orders, false)
-			for _, o := range orderedKey {
-				items = strings.Split(o.Key, "_shenwei356_")
-				items = append(items, strconv.Itoa(counter[o.Key]))
-				checkError(writer.Write(items))
+			cumulative = 0
+			for _, item = range items {
+				row = make([]string, 0, numFieldsG+8)
+				if numFieldsG > 0 {
+					row = append(row, strings.Split(group, "_shenwei356_")...)
+				}
+				row = append(row, strings.Split(item.key, "_shenwei356_")...)
+				row = append(row, formatFreq(item.count))
+				if addProportion {
+					row = append(row, formatProportion(item.count/table.total))
+				}
+				if addCumulative {
+					cumulative += item.count
+					row = append(row, formatFreq(cumulative))
+					if addProportion {
+						row = append(row, formatProportion(cumulative/table.total))
+					}
+				}
+				checkError(writer.Write(row))
 			}
 		}
 
@@ -183,6 +330,19 @@ var freqCmd = &cobra.Command{
 	},
 }
 
+// freqItem is the frequency of a key.
+type freqItem struct {
+	key   string
+	count float64
+	order int // line number of the last occurrence
+}
+
+// freqTable stores frequencies of keys in a group.
+type freqTable struct {
+	counter map[string]*freqItem
+	total   float64
+}
+
 func init() {
 	RootCmd.AddCommand(freqCmd)
 	freqCmd.Flags().StringP("fields", "f", "1", `select these fields as the key. e.g -f 1,2 or -f columnA,columnB`)
@@ -191,4 +351,12 @@ func init() {
 	freqCmd.Flags().BoolP("sort-by-freq", "n", false, `sort by frequency`)
 	freqCmd.Flags().BoolP("sort-by-key", "k", false, `sort by key`)
 	freqCmd.Flags().BoolP("reverse", "r", false, `reverse order while sorting`)
+	freqCmd.Flags().BoolP("proportion", "p", false, `append a column of the proportion of frequency`)
+	freqCmd.Flags().BoolP("cumulative", "c", false, `append columns of the cumulative frequency (and proportion if -p given)`)
+	freqCmd.Flags().BoolP("percent", "", false, `output proportions as percentages, for -p/--proportion`)
+	freqCmd.Flags().IntP("decimal-width", "w", 4, `limit floats to N decimal points`)
+	freqCmd.Flags().IntP("top", "", 0, `only output the first N keys, the remaining ones are merged as "other". 0 for all`)
+	freqCmd.Flags().StringP("other-label", "", "other", `key label of the merged record of the remaining keys, for --top`)
+	freqCmd.Flags().StringP("weight-field", "W", "", `sum up values of this field as the frequency, instead of counting records`)
+	freqCmd.Flags().StringP("groups", "g", "", `compute frequencies in each group of these fields. e.g -g 1,2 or -g columnA,columnB`)
 }
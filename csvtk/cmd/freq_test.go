@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestFreq(t *testing.T) {
+	file := testFile(t, "f.csv", "g,k,w\nx,a,1\nx,b,2\ny,a,3\nx,a,4\ny,c,0.5\nx,c,1\n")
+
+	cases := []struct {
+		args   []string
+		expect string
+	}{
+		// keys are outputted in the order of their last occurrences by default
+		{
+			[]string{"-f", "k"},
+			"k,frequency\nb,1\na,3\nc,2\n",
+		},
+		{
+			[]string{"-f", "k", "-p", "-c", "-w", "3"},
+			"k,frequency,proportion,cumulative_frequency,cumulative_proportion\n" +
+				"b,1,0.167,1,0.167\na,3,0.500,4,0.667\nc,2,0.333,6,1.000\n",
+		},
+		{
+			[]string{"-f", "k", "-k", "-p", "-c", "--percent", "-w", "1"},
+			"k,frequency,proportion,cumulative_frequency,cumulative_proportion\n" +
+				"a,3,50.0,3,50.0\nb,1,16.7,4,66.7\nc,2,33.3,6,100.0\n",
+		},
+		// records are sorted by frequency in descending order for --top
+		{
+			[]string{"-f", "k", "--top", "2", "--other-label", "rest"},
+			"k,frequency\na,3\nc,2\nrest,1\n",
+		},
+		{
+			[]string{"-f", "k", "-n", "--top", "1"},
+			"k,frequency\nb,1\nother,5\n",
+		},
+		{
+			[]string{"-f", "k", "-W", "w"},
+			"k,frequency\nb,2\na,8\nc,1.5000\n",
+		},
+		{
+			[]string{"-f", "k", "-g", "g", "-p", "-w", "2"},
+			"g,k,frequency,proportion\nx,b,1,0.25\nx,a,2,0.50\nx,c,1,0.25\ny,a,1,0.50\ny,c,1,0.50\n",
+		},
+		{
+			[]string{"-f", "2", "-g", "1", "-W", "3", "-n", "-r", "--top", "1"},
+			"g,k,frequency\nx,a,5\nx,other,3\ny,a,3\ny,other,0.5000\n",
+		},
+		{
+			[]string{"-f", "k,g", "-k"},
+			"k,g,frequency\na,x,2\na,y,1\nb,x,1\nc,x,1\nc,y,1\n",
+		},
+	}
+	for _, c := range cases {
+		args := append([]string{"freq", file}, c.args...)
+		if got := runCsvtk(t, args...); got != c.expect {
+			t.Errorf("csvtk %v:\nwant:\n%s\ngot:\n%s", args, c.expect, got)
+		}
+	}
+
+	// fields of groups and weights should be countable
+	for _, args := range [][]string{
+		{"freq", file, "-f", "k", "-g", "1-"},
+		{"freq", file, "-f", "k", "-W", "w,g"},
+		{"freq", file, "-F", "-f", "k", "-g", "g"},
+	} {
+		if _, err := execCsvtkInSubprocess(t, args...); err == nil {
+			t.Errorf("csvtk %v: error expected", args)
+		}
+	}
+}
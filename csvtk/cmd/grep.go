@@ -22,7 +22,6 @@ package cmd
 
 import (
 	"bytes"
-	"encoding/csv"
 	"fmt"
 	"io"
 	"regexp"
@@ -139,30 +138,20 @@ Attentions:
 
 		fuzzyFields := getFlagBool(cmd, "fuzzy-fields")
 
-		var writer *csv.Writer
+		var writer RecordWriter
 		var outfhStd io.Writer
-		var outfhFile *xopen.Writer
+		var outfhFile *OutWriter
 		var err error
 		isstdin := isStdin(config.OutFile)
-		if isstdin {
+		if isstdin && !isBufferedOutFormat(config.OutFormat) {
 			outfhStd = colorable.NewColorableStdout()
-			writer = csv.NewWriter(outfhStd)
+			writer = newRecordWriter(config, outfhStd)
 		} else {
 			noHighlight = true
-			outfhFile, err = xopen.Wopen(config.OutFile)
+			outfhFile, err = wopenByConfig(config)
 			checkError(err)
 			defer outfhFile.Close()
-			writer = csv.NewWriter(outfhFile)
-		}
-
-		if config.OutTabs || config.Tabs {
-			if config.OutDelimiter == ',' {
-				writer.Comma = '\t'
-			} else {
-				writer.Comma = config.OutDelimiter
-			}
-		} else {
-			writer.Comma = config.OutDelimiter
+			writer = newRecordWriter(config, outfhFile)
 		}
 		defer func() {
 			writer.Flush()
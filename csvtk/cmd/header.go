@@ -50,7 +50,7 @@ var headersCmd = &cobra.Command{
 			}
 		}
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
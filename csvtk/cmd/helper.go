@@ -22,15 +22,17 @@ package cmd
 
 import (
 	"bufio"
-	"encoding/csv"
 	"fmt"
+	"math/rand"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 	"github.com/shenwei356/breader"
@@ -43,10 +45,163 @@ import (
 func checkError(err error) {
 	if err != nil {
 		log.Error(err)
+		removeTmpFiles()
 		os.Exit(-1)
 	}
 }
 
+// tmpFiles stores temporary output files, which are removed on errors.
+var tmpFiles sync.Map
+
+func removeTmpFiles() {
+	tmpFiles.Range(func(key, value interface{}) bool {
+		os.Remove(key.(string))
+		return true
+	})
+}
+
+// OutWriter is a buffered writer of an output file. For regular files,
+// data are written into a temporary file in the same directory, which is
+// renamed to the output file in Close. So a failed command never leaves
+// a half-written output file, and the output file can also be an input file.
+type OutWriter struct {
+	*xopen.Writer
+
+	file         string
+	tmpFile      string // empty for stdout and non-regular files
+	backupSuffix string // backup the existing output file with this suffix
+
+	beforeClose []func() error // e.g., rendering buffered records, see renderOnClose
+}
+
+// tmpFileName returns a temporary file in the same directory of an output file.
+// The base name is kept as the suffix for detecting the compression format.
+func tmpFileName(file string) string {
+	dir, base := filepath.Split(file)
+	return filepath.Join(dir, fmt.Sprintf(".csvtk-tmp-%d-%d-%s", os.Getpid(), rand.Int63(), base))
+}
+
+// wopen opens an output file for atomic writing.
+func wopen(file string, backupSuffix string) (*OutWriter, error) {
+	if err := checkCompressLevel(file, xopen.Level); err != nil {
+		return nil, err
+	}
+	return wopenFile(file, backupSuffix, false)
+}
+
+// wopenRaw is similar to wopen, but data are written as they are,
+// without compression, e.g., for copying raw data of input files.
+func wopenRaw(file string, backupSuffix string) (*OutWriter, error) {
+	return wopenFile(file, backupSuffix, true)
+}
+
+func wopenFile(file string, backupSuffix string, raw bool) (*OutWriter, error) {
+	if isStdin(file) {
+		w, err := xopen.Wopen(file)
+		return &OutWriter{Writer: w, file: file}, err
+	}
+
+	info, err := os.Stat(file)
+	if err == nil && !info.Mode().IsRegular() { // e.g., /dev/null
+		w, err := xopen.Wopen(file)
+		return &OutWriter{Writer: w, file: file}, err
+	}
+
+	tmpFile := tmpFileName(file)
+	if raw { // the compression format is detected by the file suffix
+		tmpFile += ".tmp"
+	}
+	// the temporary file might be created even if failed to open it
+	tmpFiles.Store(tmpFile, struct{}{})
+	w, err := xopen.Wopen(tmpFile)
+	if err != nil {
+		os.Remove(tmpFile)
+		tmpFiles.Delete(tmpFile)
+		return nil, err
+	}
+
+	if info != nil { // keep the file mode of the existing file
+		if err = os.Chmod(tmpFile, info.Mode().Perm()); err != nil {
+			w.Close()
+			os.Remove(tmpFile)
+			tmpFiles.Delete(tmpFile)
+			return nil, err
+		}
+	}
+
+	return &OutWriter{Writer: w, file: file, tmpFile: tmpFile, backupSuffix: backupSuffix}, nil
+}
+
+// checkCompressLevel checks if the compression level is supported by the
+// compression format of the output file, which is detected by the file suffix.
+// -1 means the default level of each format.
+func checkCompressLevel(file string, level int) error {
+	if level == -1 {
+		return nil
+	}
+	var format string
+	var min, max int
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".gz":
+		format, min, max = "gzip", 0, 9
+	case ".zst":
+		format, min, max = "zstd", 1, 4
+	case ".bz2":
+		format, min, max = "bzip2", 1, 9
+	case ".lz4":
+		format, min, max = "lz4", 0, 9
+	default: // no compression or xz, the level is ignored
+		return nil
+	}
+	if level < min || level > max {
+		return fmt.Errorf("value of flag --compress-level should be in range of [%d, %d] (or -1 for the default level) for %s format: %s", min, max, format, file)
+	}
+	return nil
+}
+
+// wopenByConfig opens the output file given by -o/--out-file or --in-place.
+func wopenByConfig(config Config) (*OutWriter, error) {
+	return wopen(config.OutFile, config.BackupSuffix)
+}
+
+// Close flushes and closes the temporary file, and renames it to the output file.
+func (w *OutWriter) Close() error {
+	var err error
+	for _, f := range w.beforeClose {
+		if err = f(); err != nil {
+			break
+		}
+	}
+	w.beforeClose = nil
+	if err2 := w.Writer.Close(); err == nil {
+		err = err2
+	}
+	if w.tmpFile == "" {
+		return err
+	}
+	defer tmpFiles.Delete(w.tmpFile)
+
+	if err != nil {
+		os.Remove(w.tmpFile)
+		return err
+	}
+
+	if w.backupSuffix != "" {
+		if _, err = os.Stat(w.file); err == nil {
+			if err = os.Rename(w.file, w.file+w.backupSuffix); err != nil {
+				os.Remove(w.tmpFile)
+				return fmt.Errorf("backup file %s: %s", w.file, err)
+			}
+		}
+	}
+
+	if err = os.Rename(w.tmpFile, w.file); err != nil {
+		os.Remove(w.tmpFile)
+		return fmt.Errorf("rename temporary file to %s: %s", w.file, err)
+	}
+	return nil
+}
+
 func getFileList(args []string, checkFile bool) []string {
 	files := make([]string, 0, 1000)
 	if len(args) == 0 {
@@ -104,10 +259,20 @@ func getFileListFromFile(file string, checkFile bool) ([]string, error) {
 
 func getFileListFromArgsAndFile(cmd *cobra.Command, args []string, checkFileFromArgs bool, flag string, checkFileFromFile bool) []string {
 	infileList := getFlagString(cmd, flag)
+	recursive := getFlagBool(cmd, "recursive")
+	includes := getFlagStringSlice(cmd, "file-include")
+	excludes := getFlagStringSlice(cmd, "file-exclude")
+	checkError(checkFilePatterns(includes, "file-include"))
+	checkError(checkFilePatterns(excludes, "file-exclude"))
+
+	args, err := expandFileList(args, recursive, includes, excludes)
+	checkError(err)
 	files := getFileList(args, checkFileFromArgs)
 	if infileList != "" {
 		_files, err := getFileListFromFile(infileList, checkFileFromFile)
 		checkError(err)
+		_files, err = expandFileList(_files, recursive, includes, excludes)
+		checkError(err)
 		if len(_files) == 0 {
 			if !getFlagBool(cmd, "quiet") {
 				log.Warningf("no files found in file list: %s", infileList)
@@ -123,6 +288,222 @@ func getFileListFromArgsAndFile(cmd *cobra.Command, args []string, checkFileFrom
 	return files
 }
 
+var reGlobMeta = regexp.MustCompile(`[*?\[]`)
+
+// expandFileList expands directories and glob patterns in the file list.
+// Glob patterns support "**", which matches zero or more directories.
+// Files of directories are only searched recursively when recursive is true.
+// includes and excludes are glob patterns of base names, and they are only
+// applied to files found in directories or matched by glob patterns.
+func expandFileList(files []string, recursive bool, includes, excludes []string) ([]string, error) {
+	list := make([]string, 0, len(files))
+	for _, file := range files {
+		if isStdin(file) {
+			list = append(list, file)
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err == nil {
+			if !info.IsDir() {
+				list = append(list, file)
+				continue
+			}
+
+			_files, err := listFilesInDir(file, recursive, includes, excludes)
+			if err != nil {
+				return nil, err
+			}
+			if len(_files) == 0 {
+				return nil, fmt.Errorf("no files found in directory: %s", file)
+			}
+			list = append(list, _files...)
+			continue
+		}
+
+		if !os.IsNotExist(err) || !reGlobMeta.MatchString(file) {
+			list = append(list, file) // leave it to getFileList
+			continue
+		}
+
+		_files, err := globFiles(file, includes, excludes)
+		if err != nil {
+			return nil, err
+		}
+		if len(_files) == 0 {
+			return nil, fmt.Errorf("no files matched: %s", file)
+		}
+		list = append(list, _files...)
+	}
+	return list, nil
+}
+
+func checkFilePatterns(patterns []string, flag string) error {
+	for _, p := range patterns {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern of flag --%s: %s", flag, p)
+		}
+	}
+	return nil
+}
+
+// filterFileByPatterns checks if the base name of a file matches any of
+// includes (if given) and none of excludes.
+func filterFileByPatterns(file string, includes, excludes []string) bool {
+	base := filepath.Base(file)
+	var ok bool
+	if len(includes) > 0 {
+		for _, p := range includes {
+			if ok, _ = filepath.Match(p, base); ok {
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	for _, p := range excludes {
+		if ok, _ = filepath.Match(p, base); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// isFile checks if the entry is a regular file or a symbolic link to a regular file.
+func isFile(path string, d os.DirEntry) bool {
+	if d.Type().IsRegular() {
+		return true
+	}
+	if d.Type()&os.ModeSymlink != 0 {
+		info, err := os.Stat(path)
+		return err == nil && info.Mode().IsRegular()
+	}
+	return false
+}
+
+func listFilesInDir(dir string, recursive bool, includes, excludes []string) ([]string, error) {
+	files := make([]string, 0, 128)
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isFile(path, d) && filterFileByPatterns(path, includes, excludes) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list files in directory '%s': %s", dir, err)
+	}
+	return files, nil
+}
+
+func globFiles(pattern string, includes, excludes []string) ([]string, error) {
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+
+	// the leading segments without meta characters are the root directory
+	var i int
+	for i = 0; i < len(segments)-1; i++ {
+		if reGlobMeta.MatchString(segments[i]) {
+			break
+		}
+	}
+	root := strings.Join(segments[:i], "/")
+	if root == "" {
+		if i > 0 { // absolute path
+			root = "/"
+		} else {
+			root = "."
+		}
+	}
+	root = filepath.FromSlash(root)
+	patterns := segments[i:]
+
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern: %s", pattern)
+		}
+	}
+
+	var anyDepth bool
+	for _, p := range patterns {
+		if p == "**" {
+			anyDepth = true
+			break
+		}
+	}
+
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	files := make([]string, 0, 128)
+	err := filepath.WalkDir(root, func(_path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if _path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, _path)
+		if err != nil {
+			return err
+		}
+		relSegments := strings.Split(filepath.ToSlash(rel), "/")
+
+		if d.IsDir() {
+			if !anyDepth && len(relSegments) >= len(patterns) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isFile(_path, d) && matchGlobSegments(patterns, relSegments) &&
+			filterFileByPatterns(_path, includes, excludes) {
+			files = append(files, _path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("match files with glob pattern '%s': %s", pattern, err)
+	}
+	return files, nil
+}
+
+// matchGlobSegments matches path segments with pattern segments, where "**"
+// matches zero or more segments.
+func matchGlobSegments(patterns, segments []string) bool {
+	var ok bool
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			patterns = patterns[1:]
+			if len(patterns) == 0 {
+				return true
+			}
+			for i := range segments {
+				if matchGlobSegments(patterns, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ = path.Match(patterns[0], segments[0]); !ok {
+			return false
+		}
+		patterns, segments = patterns[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
 func getFlagInt(cmd *cobra.Command, flag string) int {
 	value, err := cmd.Flags().GetInt(flag)
 	checkError(err)
@@ -278,7 +659,15 @@ type Config struct {
 
 	ShowRowNumber bool
 
-	OutFile string
+	ShowFilename    bool
+	FilenameBase    bool
+	FilenameTrimExt bool
+	ShowLineNumber  bool
+
+	OutFile       string
+	OutFormat     string
+	CompressLevel int
+	BackupSuffix  string
 
 	IgnoreEmptyRow   bool
 	IgnoreIllegalRow bool
@@ -332,6 +721,33 @@ func getConfigs(cmd *cobra.Command) Config {
 		threads = runtime.NumCPU()
 	}
 
+	compressLevel := getFlagInt(cmd, "compress-level")
+	if compressLevel < -1 || compressLevel > 9 {
+		checkError(fmt.Errorf("value of flag --compress-level should be in range of [-1, 9]"))
+	}
+	xopen.Level = compressLevel
+
+	outFormat, err := checkOutFormat(getFlagString(cmd, "out-format"))
+	checkError(err)
+
+	outFile := getFlagString(cmd, "out-file")
+	backupSuffix := getFlagString(cmd, "backup-suffix")
+	if backupSuffix != "" && strings.ContainsRune(backupSuffix, os.PathSeparator) {
+		checkError(fmt.Errorf("value of flag --backup-suffix should not contain path separator"))
+	}
+	if getFlagBool(cmd, "in-place") {
+		if cmd.Flags().Lookup("out-file").Changed {
+			checkError(fmt.Errorf("flag --in-place and -o/--out-file are exclusive"))
+		}
+		files := getFileListFromArgsAndFile(cmd, cmd.Flags().Args(), true, "infile-list", true)
+		if len(files) != 1 || isStdin(files[0]) {
+			checkError(fmt.Errorf("flag --in-place only supports one input file, but not stdin"))
+		}
+		outFile = files[0]
+	} else if backupSuffix != "" {
+		checkError(fmt.Errorf("flag --backup-suffix only works with --in-place"))
+	}
+
 	return Config{
 		Verbose: verbose,
 		NumCPUs: threads,
@@ -349,7 +765,15 @@ func getConfigs(cmd *cobra.Command) Config {
 
 		ShowRowNumber: getFlagBool(cmd, "show-row-number"),
 
-		OutFile: getFlagString(cmd, "out-file"),
+		ShowFilename:    getFlagBool(cmd, "show-filename"),
+		FilenameBase:    getFlagBool(cmd, "filename-base"),
+		FilenameTrimExt: getFlagBool(cmd, "filename-trim-ext"),
+		ShowLineNumber:  getFlagBool(cmd, "show-line-number"),
+
+		OutFile:       outFile,
+		OutFormat:     outFormat,
+		CompressLevel: compressLevel,
+		BackupSuffix:  backupSuffix,
 
 		IgnoreEmptyRow:   getFlagBool(cmd, "ignore-empty-row"),
 		IgnoreIllegalRow: getFlagBool(cmd, "ignore-illegal-row"),
@@ -373,24 +797,24 @@ func newCSVReaderByConfig(config Config, file string) (*CSVReader, error) {
 
 	reader.NoHeaderRow = config.NoHeaderRow
 
+	reader.ShowFilename = config.ShowFilename
+	reader.FilenameBase = config.FilenameBase
+	reader.FilenameTrimExt = config.FilenameTrimExt
+	reader.ShowLineNumber = config.ShowLineNumber
+
 	return reader, nil
 }
 
 // NewCSVWriterChanByConfig returns a chanel which you can send record to write
 func NewCSVWriterChanByConfig(config Config) (chan []string, error) {
-	outfh, err := xopen.Wopen(config.OutFile)
+	outfh, err := wopenByConfig(config)
 	if err != nil {
 		return nil, err
 	}
 
 	ch := make(chan []string, config.NumCPUs)
 
-	writer := csv.NewWriter(outfh)
-	if config.OutTabs {
-		writer.Comma = '\t'
-	} else {
-		writer.Comma = config.OutDelimiter
-	}
+	writer := newRecordWriter(config, outfh)
 	go func() {
 		defer outfh.Close()
 		for record := range ch {
@@ -416,6 +840,23 @@ var reIntegerRange = regexp.MustCompile(`^([\-\d]+?)\-([\-\d]*?)$`)
 var reIntegerRange2 = regexp.MustCompile(`^(\d+)\-(\d+)$`)
 var reIntegerRangeOnlyStart = regexp.MustCompile(`^(\d+)\-$`)
 
+// countFields returns the number of fields in a field string, e.g., 3 for "1,3-4",
+// or -1 if it can not be known before reading the header row, e.g., "3-",
+// unselected fields, and fuzzy fields.
+func countFields(fieldStr string, fuzzyFields bool) int {
+	if fuzzyFields {
+		return -1
+	}
+	fields, colnames, negativeFields, _, x2ends := parseFields(fieldStr, ",", false, false)
+	if negativeFields || x2ends != nil {
+		return -1
+	}
+	if fields != nil {
+		return len(fields)
+	}
+	return len(colnames)
+}
+
 func getFlagFields(cmd *cobra.Command, flag string) string {
 	fieldsStr, err := cmd.Flags().GetString(flag)
 	checkError(err)
@@ -686,9 +1127,12 @@ func filepathTrimExtension(file string) (string, string) {
 	return name, extension
 }
 
+// CompressionSuffixes are suffixes of supported compression formats.
+var CompressionSuffixes = []string{".gz", ".xz", ".zst", ".bz2", ".lz4"}
+
 func filepathTrimExtension2(file string, suffixes []string) (string, string, string) {
 	if suffixes == nil {
-		suffixes = []string{".gz", ".xz", ".zst"}
+		suffixes = CompressionSuffixes
 	}
 
 	var e, e1, e2 string
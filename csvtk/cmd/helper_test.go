@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchGlobSegments(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		expect  bool
+	}{
+		{"*.csv", "a.csv", true},
+		{"*.csv", "a.tsv", false},
+		{"*.csv", "d/a.csv", false},
+		{"*/*.csv", "d/a.csv", true},
+		{"**", "a.csv", true},
+		{"**", "d/e/a.csv", true},
+		{"**/*.csv", "a.csv", true},
+		{"**/*.csv", "d/e/a.csv", true},
+		{"**/*.csv", "d/e/a.tsv", false},
+		{"d/**/a.csv", "d/a.csv", true},
+		{"d/**/a.csv", "d/e/f/a.csv", true},
+		{"d/**/a.csv", "e/a.csv", false},
+		{"d/**/e/*.csv", "d/x/e/a.csv", true},
+		{"d/**/e/*.csv", "d/x/f/a.csv", false},
+		{"?.csv", "ab.csv", false},
+		{"[ab].csv", "b.csv", true},
+	}
+	for _, c := range cases {
+		got := matchGlobSegments(strings.Split(c.pattern, "/"), strings.Split(c.path, "/"))
+		if got != c.expect {
+			t.Errorf("matchGlobSegments(%q, %q): want %v, got %v", c.pattern, c.path, c.expect, got)
+		}
+	}
+}
+
+func TestExpandFileList(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{
+		"a.csv",
+		"b.tsv",
+		"c.csv.gz",
+		"sub/d.csv",
+		"sub/e.tsv",
+		"sub/deep/f.csv",
+	} {
+		file = filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte("a\n1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := func(files ...string) []string {
+		for i, file := range files {
+			files[i] = filepath.Join(dir, filepath.FromSlash(file))
+		}
+		return files
+	}
+
+	cases := []struct {
+		files     []string
+		recursive bool
+		includes  []string
+		excludes  []string
+		expect    []string
+		err       bool
+	}{
+		// stdin and regular files are kept
+		{files: []string{"-"}, expect: []string{"-"}},
+		{files: p("b.tsv", "a.csv"), expect: p("b.tsv", "a.csv")},
+		// directories
+		{files: p("."), expect: p("a.csv", "b.tsv", "c.csv.gz")},
+		{files: p("."), recursive: true, expect: p("a.csv", "b.tsv", "c.csv.gz", "sub/d.csv", "sub/deep/f.csv", "sub/e.tsv")},
+		{files: p("."), recursive: true, includes: []string{"*.csv"}, expect: p("a.csv", "sub/d.csv", "sub/deep/f.csv")},
+		{files: p("."), recursive: true, excludes: []string{"*.csv", "*.gz"}, expect: p("b.tsv", "sub/e.tsv")},
+		// glob patterns
+		{files: p("*.csv"), expect: p("a.csv")},
+		{files: p("*/*.csv"), expect: p("sub/d.csv")},
+		{files: p("**/*.csv"), expect: p("a.csv", "sub/d.csv", "sub/deep/f.csv")},
+		{files: p("sub/**"), excludes: []string{"*.tsv"}, expect: p("sub/d.csv", "sub/deep/f.csv")},
+		// nothing matched
+		{files: p("*.xlsx"), err: true},
+		{files: p("sub/deep"), excludes: []string{"*"}, err: true},
+		// not existing files without meta characters are left to getFileList
+		{files: p("x.csv"), expect: p("x.csv")},
+	}
+
+	for _, c := range cases {
+		got, err := expandFileList(c.files, c.recursive, c.includes, c.excludes)
+		if c.err {
+			if err == nil {
+				t.Errorf("expandFileList(%v): error expected, got %v", c.files, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("expandFileList(%v): %s", c.files, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.expect) {
+			t.Errorf("expandFileList(%v, recursive: %v, includes: %v, excludes: %v):\nwant: %v\ngot:  %v",
+				c.files, c.recursive, c.includes, c.excludes, c.expect, got)
+		}
+	}
+}
+
+func TestCheckCompressLevel(t *testing.T) {
+	cases := []struct {
+		file  string
+		level int
+		err   bool
+	}{
+		{"a.csv", 100, false}, // ignored for uncompressed files
+		{"a.csv.xz", 9, false},
+		{"a.csv.gz", -1, false},
+		{"a.csv.gz", 0, false},
+		{"a.csv.gz", 9, false},
+		{"a.csv.gz", 10, true},
+		{"a.csv.zst", 0, true},
+		{"a.csv.zst", 4, false},
+		{"a.csv.zst", 5, true},
+		{"a.csv.bz2", 0, true},
+		{"a.csv.bz2", 9, false},
+		{"a.csv.lz4", 0, false},
+		{"a.csv.LZ4", 10, true},
+	}
+	for _, c := range cases {
+		if err := checkCompressLevel(c.file, c.level); (err != nil) != c.err {
+			t.Errorf("checkCompressLevel(%s, %d): want error: %v, got %v", c.file, c.level, c.err, err)
+		}
+	}
+}
+
+func TestCompressLevel(t *testing.T) {
+	file := testFile(t, "a.csv", "a,b\n"+strings.Repeat("1,2\n", 1000))
+	expect := "a\n" + strings.Repeat("1\n", 1000)
+
+	outdir := t.TempDir()
+	sizes := make(map[string]int64)
+	for _, c := range []struct {
+		out   string
+		level string
+	}{
+		{"0.csv.gz", "0"},
+		{"9.csv.gz", "9"},
+		{"1.csv.zst", "1"},
+		{"default.csv.bz2", "-1"},
+		{"0.csv.lz4", "0"},
+	} {
+		outFile := filepath.Join(outdir, c.out)
+		execCsvtk(t, "cut", "-f", "a", "--compress-level", c.level, file, "-o", outFile)
+		info, err := os.Stat(outFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes[c.out] = info.Size()
+	}
+	for file, data := range readOutFiles(t, outdir) {
+		if data != expect {
+			t.Errorf("--compress-level: unexpected content of %s: %q", file, data)
+		}
+	}
+	// gzip level 0 means no compression
+	if sizes["0.csv.gz"] <= sizes["9.csv.gz"] {
+		t.Errorf("--compress-level: gzip file of level 0 (%d bytes) should be larger than that of level 9 (%d bytes)",
+			sizes["0.csv.gz"], sizes["9.csv.gz"])
+	}
+
+	// the compression format of split outputs
+	outdir = filepath.Join(t.TempDir(), "split")
+	execCsvtk(t, "split", "-n", "600", "--out-compress", "zst", "--compress-level", "4", "-p", "part-", file, "-o", outdir)
+	files := readOutFiles(t, outdir)
+	if len(files) != 2 || files["part-001.csv.zst"] != "a,b\n"+strings.Repeat("1,2\n", 600) ||
+		files["part-002.csv.zst"] != "a,b\n"+strings.Repeat("1,2\n", 400) {
+		t.Errorf("split --out-compress: unexpected output files: %v", files)
+	}
+}
+
+// tmpOutFiles returns temporary output files left in a directory.
+func tmpOutFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, ".csvtk-tmp-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestAtomicOutput(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.csv")
+	data := "a,b\n1,2\n3,4\n"
+	if err := os.WriteFile(file, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// in place, with a backup
+	execCsvtk(t, "cut", "-f", "b", "--in-place", "--backup-suffix", ".bak", file)
+	if got, _ := os.ReadFile(file); string(got) != "b\n2\n4\n" {
+		t.Errorf("--in-place: unexpected output: %q", got)
+	}
+	if got, _ := os.ReadFile(file + ".bak"); string(got) != data {
+		t.Errorf("--backup-suffix: unexpected backup: %q", got)
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("--in-place: the file mode is not kept: %v", info.Mode())
+	}
+
+	// failed commands keep the existing output file and leave no temporary files
+	outFile := filepath.Join(dir, "out.csv")
+	if err := os.WriteFile(outFile, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"cut", "-f", "x", file + ".bak", "-o", outFile},
+		{"cat", "-L", file + ".bak", "-o", outFile},
+		{"plot", "hist", "-f", "a", "--in-place", file + ".bak"},
+	} {
+		if _, err := execCsvtkInSubprocess(t, args...); err == nil {
+			t.Errorf("csvtk %v: error expected", args)
+		}
+		if got, _ := os.ReadFile(outFile); string(got) != data {
+			t.Errorf("csvtk %v: the existing output file is changed: %q", args, got)
+		}
+		if got, _ := os.ReadFile(file + ".bak"); string(got) != data {
+			t.Errorf("csvtk %v: the input file is changed: %q", args, got)
+		}
+		if files := tmpOutFiles(t, dir); len(files) > 0 {
+			t.Errorf("csvtk %v: temporary files left: %v", args, files)
+		}
+	}
+}
@@ -21,12 +21,14 @@
 package cmd
 
 import (
-	"encoding/csv"
 	"fmt"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/araddon/dateparse"
 	"github.com/shenwei356/xopen"
 	"github.com/spf13/cobra"
 )
@@ -46,6 +48,31 @@ Attention:
   2. Default operation is inner join, use --left-join for left join 
      and --outer-join for outer join.
 
+Interval join:
+
+  Records are joined on overlap of numeric or date ranges, rather than
+  equality, with --interval giving range fields of all files, which
+  are separated by ";" as -f/--fields.
+
+  1. Point-in-interval: one field for the first file, and two fields
+     (start and end) for other files, e.g., --interval "pos;start,end".
+     A point matches an interval if start <= pos < end.
+  2. Interval overlap: two fields for all files, e.g., --interval "s,e".
+     Two intervals overlap if start1 < end2 and start2 < end1.
+  3. Use --closed for closed intervals, i.e., start <= pos <= end, and
+     start1 <= end2 and start2 <= end1.
+  4. Key fields (-f/--fields) are optional and matched by equality, e.g.,
+     chromosomes, in which only key fields of other files are removed.
+  5. Use --date for ranges of date/time, which are parsed by
+     https://github.com/araddon/dateparse.
+  6. Only inner join and left join are supported. Records of other files
+     with unparsed ranges are skipped, and records of the first file are
+     treated as unmatched. Fuzzy fields are not supported.
+
+  Example:
+
+    $ csvtk join -f chr --interval "pos;start,end" variants.csv genes.csv
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		config := getConfigs(cmd)
@@ -67,6 +94,29 @@ Attention:
 			checkError(fmt.Errorf("number of fields (%d) should be equal to number of files (%d)", len(allFields), len(files)))
 		}
 
+		allIntervals := getFlagSemicolonSeparatedStrings(cmd, "interval")
+		intervalMode := len(allIntervals) > 0
+		if intervalMode {
+			if len(allIntervals) == 1 {
+				s := make([]string, len(files))
+				for i := range files {
+					s[i] = allIntervals[0]
+				}
+				allIntervals = s
+			} else if len(allIntervals) != len(files) {
+				checkError(fmt.Errorf("number of interval fields (%d) should be equal to number of files (%d)", len(allIntervals), len(files)))
+			}
+
+			// key fields are optional in interval join
+			if !cmd.Flags().Lookup("fields").Changed {
+				for i := range allFields {
+					allFields[i] = ""
+				}
+			}
+		}
+		closed := getFlagBool(cmd, "closed")
+		isDate := getFlagBool(cmd, "date")
+
 		ignoreCase := getFlagBool(cmd, "ignore-case")
 		filenameAsPrefix := getFlagBool(cmd, "prefix-filename")
 		trimeExtention := getFlagBool(cmd, "prefix-trim-ext")
@@ -92,6 +142,34 @@ Attention:
 			checkError(fmt.Errorf("flag -O/--out-join and -L/--left-join are exclusive"))
 		}
 
+		if intervalMode {
+			if outerJoin {
+				checkError(fmt.Errorf("flag -O/--outer-join is not supported in interval join"))
+			}
+			if fuzzyFields {
+				checkError(fmt.Errorf("flag -F/--fuzzy-fields is not supported in interval join"))
+			}
+		}
+
+		// parseRange parses a value of range fields
+		parseRange := func(s string) (float64, bool) {
+			if isDate {
+				t, err := dateparse.ParseLocal(s)
+				if err != nil {
+					return 0, false
+				}
+				return float64(t.UnixNano()) / 1e9, true
+			}
+			if !reDigitals.MatchString(s) {
+				return 0, false
+			}
+			v, err := strconv.ParseFloat(removeComma(s), 64)
+			if err != nil {
+				return 0, false
+			}
+			return v, true
+		}
+
 		if outerJoin {
 			keepUnmatched = true
 			for _, file := range files {
@@ -104,20 +182,11 @@ Attention:
 			keepUnmatched = true
 		}
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
-		writer := csv.NewWriter(outfh)
-		if config.OutTabs || config.Tabs {
-			if config.OutDelimiter == ',' {
-				writer.Comma = '\t'
-			} else {
-				writer.Comma = config.OutDelimiter
-			}
-		} else {
-			writer.Comma = config.OutDelimiter
-		}
+		writer := newRecordWriter(config, outfh)
 		defer func() {
 			writer.Flush()
 			checkError(writer.Error())
@@ -135,6 +204,7 @@ Attention:
 		}
 		var Data [][]string
 		var Fields []int
+		var FieldsR []int // range fields of the first file, for interval join
 		firstFile := true
 		var withHeaderRow bool
 
@@ -183,8 +253,16 @@ Attention:
 		var ok bool
 		mColnames := make(map[string]interface{}, 8)
 		for i, file := range files {
+			fieldStr := allFields[i]
+			if intervalMode {
+				if fieldStr == "" {
+					fieldStr = allIntervals[i]
+				} else {
+					fieldStr += "," + allIntervals[i]
+				}
+			}
 			_, fields, _, headerRow, data, err := parseCSVfile(cmd, config,
-				file, allFields[i], fuzzyFields, false, true)
+				file, fieldStr, fuzzyFields, false, true)
 
 			if err != nil {
 				if err == xopen.ErrNoContent {
@@ -202,8 +280,26 @@ Attention:
 				}
 				continue
 			}
+
+			// split key fields and range fields
+			var fieldsR []int
+			if intervalMode {
+				var nKeys int
+				if allFields[i] != "" {
+					nKeys = len(strings.Split(allFields[i], ","))
+				}
+				fields, fieldsR = fields[:nKeys], fields[nKeys:]
+				if firstFile {
+					if len(fieldsR) != 1 && len(fieldsR) != 2 {
+						checkError(fmt.Errorf("one or two range fields needed for the first file: %s", allIntervals[i]))
+					}
+				} else if len(fieldsR) != 2 {
+					checkError(fmt.Errorf("two range fields (start and end) needed for file: %s", file))
+				}
+			}
+
 			if firstFile {
-				HeaderRow, Data, Fields = headerRow, data, fields
+				HeaderRow, Data, Fields, FieldsR = headerRow, data, fields, fieldsR
 				if filenameAsPrefix {
 					fieldsMap1 := make(map[int]interface{}, len(fields))
 					for _, f = range fields {
@@ -380,8 +476,32 @@ Attention:
 			}
 			// csv to map
 			keysMaps := make(map[string][][]string)
+			intervalsMaps := make(map[string]*intervalIndex)
 			items = make([]string, len(fields))
 			for _, record := range data {
+				if intervalMode {
+					start, ok1 := parseRange(record[fieldsR[0]-1])
+					end, ok2 := parseRange(record[fieldsR[1]-1])
+					if !(ok1 && ok2) {
+						continue
+					}
+					for i, f := range fields {
+						items[i] = record[f-1]
+					}
+					key = strings.Join(items, "_shenwei356_")
+					if ignoreNull && len(fields) > 0 && key == "" { // skip empty cell
+						continue
+					}
+					if ignoreCase {
+						key = strings.ToLower(key)
+					}
+					if _, ok = intervalsMaps[key]; !ok {
+						intervalsMaps[key] = &intervalIndex{}
+					}
+					intervalsMaps[key].add(start, end, record)
+					continue
+				}
+
 				for i, f := range fields {
 					items[i] = record[f-1]
 				}
@@ -491,21 +611,42 @@ Attention:
 				}
 			}
 
+			for _, idx := range intervalsMaps {
+				idx.index()
+			}
+
 			items = make([]string, len(Fields))
 			var records [][]string
 			var record2 []string
+			var idx *intervalIndex
 			for _, record0 := range Data {
 				for i, f := range Fields {
 					items[i] = record0[f-1]
 				}
 				key = strings.Join(items, "_shenwei356_")
-				if ignoreNull && key == "" { // skip empty cell
+				if ignoreNull && (!intervalMode || len(Fields) > 0) && key == "" { // skip empty cell
 					continue
 				}
 				if ignoreCase {
 					key = strings.ToLower(key)
 				}
-				if records, ok = keysMaps[key]; ok {
+				if intervalMode {
+					records = nil
+					if idx, ok = intervalsMaps[key]; ok {
+						start, ok1 := parseRange(record0[FieldsR[0]-1])
+						end, ok2 := start, true
+						if len(FieldsR) == 2 {
+							end, ok2 = parseRange(record0[FieldsR[1]-1])
+						}
+						if ok1 && ok2 {
+							records = idx.query(start, end, len(FieldsR) == 1, closed)
+						}
+					}
+					ok = len(records) > 0
+				} else {
+					records, ok = keysMaps[key]
+				}
+				if ok {
 					for _, record2 = range records {
 						record := make([]string, len(record0))
 						copy(record, record0)
@@ -568,4 +709,136 @@ func init() {
 	joinCmd.Flags().BoolP("prefix-trim-ext", "e", false, "trim extension when adding filename as colname prefix")
 	joinCmd.Flags().BoolP("only-duplicates", "P", false, "add filenames as colname prefixes or add custom suffixes only for duplicated colnames")
 	joinCmd.Flags().StringSliceP("suffix", "s", []string{}, "add suffixes to colnames from each file")
+	joinCmd.Flags().StringP("interval", "", "", `semicolon separated range fields of all files for interval join, e.g., --interval "pos;start,end" or --interval "s,e". type "csvtk join -h" for details`)
+	joinCmd.Flags().BoolP("closed", "", false, "use closed intervals for interval join, i.e., start <= pos <= end")
+	joinCmd.Flags().BoolP("date", "", false, "range fields are date/time for interval join")
+}
+
+// intervalIndex is an implicit augmented interval tree on a sorted array,
+// like cgranges (https://github.com/lh3/cgranges).
+type intervalIndex struct {
+	intervals []joinInterval
+	maxLevel  int
+}
+
+type joinInterval struct {
+	start, end float64
+	max        float64 // max end of the subtree
+	record     []string
+}
+
+func (idx *intervalIndex) add(start, end float64, record []string) {
+	idx.intervals = append(idx.intervals, joinInterval{start: start, end: end, record: record})
+}
+
+// index sorts intervals and computes max ends of subtrees.
+func (idx *intervalIndex) index() {
+	a := idx.intervals
+	sort.SliceStable(a, func(i, j int) bool { return a[i].start < a[j].start })
+
+	n := len(a)
+	if n == 0 {
+		return
+	}
+	var lastI int
+	var last float64
+	for i := 0; i < n; i += 2 {
+		lastI, last = i, a[i].end
+		a[i].max = last
+	}
+	var k int
+	for k = 1; 1<<k <= n; k++ {
+		x := 1 << (k - 1)
+		i0, step := (x<<1)-1, x<<2
+		for i := i0; i < n; i += step {
+			e := a[i].end
+			if el := a[i-x].max; el > e {
+				e = el
+			}
+			er := last
+			if i+x < n {
+				er = a[i+x].max
+			}
+			if er > e {
+				e = er
+			}
+			a[i].max = e
+		}
+		if lastI>>k&1 == 1 {
+			lastI -= x
+		} else {
+			lastI += x
+		}
+		if lastI < n && a[lastI].max > last {
+			last = a[lastI].max
+		}
+	}
+	idx.maxLevel = k - 1
+}
+
+// query returns records of intervals overlapping with [start, end), or
+// containing the point start if point is true, in the order of start positions.
+func (idx *intervalIndex) query(start, end float64, point, closed bool) [][]string {
+	a := idx.intervals
+	n := len(a)
+	if n == 0 {
+		return nil
+	}
+
+	match := func(iv *joinInterval) bool {
+		switch {
+		case point && closed:
+			return iv.start <= start && start <= iv.end
+		case point:
+			return iv.start <= start && start < iv.end
+		case closed:
+			return iv.start <= end && start <= iv.end
+		default:
+			return iv.start < end && start < iv.end
+		}
+	}
+
+	// candidates are searched with closed intervals, and then checked with match().
+	type node struct {
+		x, k int
+		w    bool // whether the left child has been processed
+	}
+	hits := make([]int, 0, 8)
+	stack := make([]node, 0, 64)
+	stack = append(stack, node{x: (1 << idx.maxLevel) - 1, k: idx.maxLevel})
+	var z node
+	for len(stack) > 0 {
+		z = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if z.k <= 3 { // small subtree, linear scan
+			i0 := z.x >> z.k << z.k
+			i1 := i0 + (1 << (z.k + 1)) - 1
+			if i1 > n {
+				i1 = n
+			}
+			for i := i0; i < i1 && a[i].start <= end; i++ {
+				if start <= a[i].end && match(&a[i]) {
+					hits = append(hits, i)
+				}
+			}
+		} else if !z.w {
+			y := z.x - (1 << (z.k - 1))
+			stack = append(stack, node{x: z.x, k: z.k, w: true})
+			if y >= n || a[y].max >= start {
+				stack = append(stack, node{x: y, k: z.k - 1})
+			}
+		} else if z.x < n && a[z.x].start <= end {
+			if start <= a[z.x].end && match(&a[z.x]) {
+				hits = append(hits, z.x)
+			}
+			stack = append(stack, node{x: z.x + (1 << (z.k - 1)), k: z.k - 1})
+		}
+	}
+
+	sort.Ints(hits)
+	records := make([][]string, len(hits))
+	for i, h := range hits {
+		records[i] = a[h].record
+	}
+	return records
 }
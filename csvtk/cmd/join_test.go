@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// queryIntervalsBruteForce returns records of matched intervals in the order of
+// sorted intervals, the same as intervalIndex.query.
+func queryIntervalsBruteForce(idx *intervalIndex, start, end float64, point, closed bool) [][]string {
+	var records [][]string
+	for _, iv := range idx.intervals {
+		var ok bool
+		switch {
+		case point && closed:
+			ok = iv.start <= start && start <= iv.end
+		case point:
+			ok = iv.start <= start && start < iv.end
+		case closed:
+			ok = iv.start <= end && start <= iv.end
+		default:
+			ok = iv.start < end && start < iv.end
+		}
+		if ok {
+			records = append(records, iv.record)
+		}
+	}
+	return records
+}
+
+func TestIntervalIndex(t *testing.T) {
+	// empty index
+	var idx intervalIndex
+	idx.index()
+	if got := idx.query(0, 10, false, false); len(got) != 0 {
+		t.Errorf("intervalIndex (empty): want no hits, got %v", got)
+	}
+
+	// a few intervals
+	idx = intervalIndex{}
+	for i, iv := range [][2]float64{{10, 20}, {0, 5}, {5, 10}, {15, 15}, {3, 30}} {
+		idx.add(iv[0], iv[1], []string{strconv.Itoa(i)})
+	}
+	idx.index()
+	cases := []struct {
+		start, end    float64
+		point, closed bool
+		expect        []string
+	}{
+		{5, 5, true, false, []string{"4", "2"}},
+		{5, 5, true, true, []string{"1", "4", "2"}},
+		{15, 15, true, false, []string{"4", "0"}},
+		{15, 15, true, true, []string{"4", "0", "3"}},
+		{20, 20, true, false, []string{"4"}},
+		{30, 30, true, false, nil},
+		{30, 30, true, true, []string{"4"}},
+		{5, 10, false, false, []string{"4", "2"}},
+		{5, 10, false, true, []string{"1", "4", "2", "0"}},
+		{31, 40, false, true, nil},
+		{-5, 0, false, false, nil},
+		{-5, 0, false, true, []string{"1"}},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, record := range idx.query(c.start, c.end, c.point, c.closed) {
+			got = append(got, record[0])
+		}
+		if !reflect.DeepEqual(got, c.expect) {
+			t.Errorf("intervalIndex.query(%v, %v, point: %v, closed: %v): want %v, got %v",
+				c.start, c.end, c.point, c.closed, c.expect, got)
+		}
+	}
+
+	// random intervals, compared with brute force
+	r := rand.New(rand.NewSource(11))
+	for _, n := range []int{1, 2, 7, 16, 17, 100, 1000} {
+		idx = intervalIndex{}
+		for i := 0; i < n; i++ {
+			start := float64(r.Intn(1000))
+			length := float64(r.Intn(50))
+			if r.Intn(20) == 0 { // long intervals
+				length = float64(r.Intn(1000))
+			}
+			idx.add(start, start+length, []string{strconv.Itoa(i)})
+		}
+		idx.index()
+
+		for q := 0; q < 500; q++ {
+			start := float64(r.Intn(1100) - 50)
+			end := start + float64(r.Intn(100))
+			for _, point := range []bool{false, true} {
+				for _, closed := range []bool{false, true} {
+					got := idx.query(start, end, point, closed)
+					expect := queryIntervalsBruteForce(&idx, start, end, point, closed)
+					if len(got) == 0 && len(expect) == 0 {
+						continue
+					}
+					if !reflect.DeepEqual(got, expect) {
+						t.Fatalf("intervalIndex.query(%v, %v, point: %v, closed: %v) with %d intervals:\nwant %v\ngot  %v",
+							start, end, point, closed, n, expect, got)
+					}
+				}
+			}
+		}
+	}
+}
@@ -22,6 +22,7 @@ package cmd
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"runtime"
 	"slices"
@@ -64,6 +65,7 @@ Notes:
 		runtime.GOMAXPROCS(config.NumCPUs)
 
 		scatter := getFlagBool(cmd, "scatter")
+		fitLine := getFlagBool(cmd, "fit-line")
 		lineWidth := vg.Points(getFlagPositiveFloat64(cmd, "line-width") * plotConfig.scale)
 		pointSize := vg.Length(getFlagPositiveFloat64(cmd, "point-size") * plotConfig.scale)
 		colorIndex := getFlagPositiveInt(cmd, "color-index")
@@ -256,6 +258,38 @@ Notes:
 				}
 			}
 
+			if fitLine && len(v) >= 2 {
+				xs, ys := make([]float64, len(v)), make([]float64, len(v))
+				minX, maxX := v[0].X, v[0].X
+				for j, xy := range v {
+					xs[j], ys[j] = xy.X, xy.Y
+					if xy.X < minX {
+						minX = xy.X
+					}
+					if xy.X > maxX {
+						maxX = xy.X
+					}
+				}
+				m := linearFit(xs, ys)
+				if math.IsNaN(m.slope) || math.IsInf(m.slope, 0) || math.IsNaN(m.intercept) || math.IsInf(m.intercept, 0) {
+					if g == "" {
+						log.Warningf("skip the fit line: the slope or intercept is not finite, e.g., all X values are the same")
+					} else {
+						log.Warningf("skip the fit line of group %s: the slope or intercept is not finite, e.g., all X values are the same", g)
+					}
+				} else {
+					line, err := plotter.NewLine(plotter.XYs{
+						{X: minX, Y: m.intercept + m.slope*minX},
+						{X: maxX, Y: m.intercept + m.slope*maxX},
+					})
+					checkError(err)
+					line.Color = plotutil.Color(i)
+					line.LineStyle.Dashes = []vg.Length{vg.Points(5), vg.Points(3)}
+					line.LineStyle.Width = lineWidth
+					p.Add(line)
+				}
+			}
+
 			i++
 		}
 		if lineWidth > pointSize {
@@ -366,6 +400,7 @@ func init() {
 	lineCmd.Flags().BoolP("legend-left", "", false, "locate legend along the left edge of the plot")
 
 	lineCmd.Flags().BoolP("scatter", "", false, "only plot points")
+	lineCmd.Flags().BoolP("fit-line", "", false, `add a dashed line of simple linear regression for each group, see also "csvtk fit"`)
 	lineCmd.Flags().Float64P("line-width", "", 1.5, "line width")
 	lineCmd.Flags().Float64P("point-size", "", 3, "point size")
 	lineCmd.Flags().IntP("color-index", "", 1, `color index, 1-7`)
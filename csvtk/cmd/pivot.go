@@ -0,0 +1,341 @@
+// Copyright © 2016-2023 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// pivotCmd represents the pivot command
+var pivotCmd = &cobra.Command{
+	GroupID: "transform",
+
+	Use:   "pivot",
+	Short: "create a pivot table with aggregated values",
+	Long: `create a pivot table with aggregated values
+
+Values of the value field (-v/--value) are grouped by row fields (-r/--rows)
+and column fields (-c/--columns), and aggregated with an operation
+(-a/--aggregate) of "csvtk summary", e.g., sum, mean, count, max, median.
+
+Attention:
+  1. Rows and columns are outputted in the order of their first appearance,
+     use --sort-rows and --sort-cols to sort them.
+  2. Values of multiple column fields are joined with --column-sep as
+     column names.
+  3. The value field is optional for operation "count".
+  4. With -m/--margins, an extra column and an extra row of aggregated
+     values of all columns and all rows are appended, which are
+     aggregated from raw values, not from values in cells.
+  5. Fields should not be ranges or fuzzy fields.
+  6. Operations like sum, mean, max and count are computed in streaming mode,
+     while all values of each cell are kept in memory for others, e.g., median.
+
+Available operations:
+  All numeric and textual operations of "csvtk summary", except for
+  approximate ones, e.g., sum, mean, median, p95, count, countunique.
+
+Examples:
+
+  $ csvtk pivot -r region -c year -v sales -a sum -m data.csv
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if len(files) > 1 {
+			checkError(fmt.Errorf("no more than one file should be given"))
+		}
+		runtime.GOMAXPROCS(config.NumCPUs)
+
+		rowsStr := getFlagString(cmd, "rows")
+		colsStr := getFlagString(cmd, "columns")
+		valueStr := getFlagString(cmd, "value")
+		op := getFlagString(cmd, "aggregate")
+		if rowsStr == "" {
+			checkError(fmt.Errorf("flag -r/--rows needed"))
+		}
+		if colsStr == "" {
+			checkError(fmt.Errorf("flag -c/--columns needed"))
+		}
+
+		fuNum, isNumeric := getStatFunc(op)
+		fuStr, isTextual := allStats2[op]
+		if !(isNumeric || isTextual) {
+			checkError(fmt.Errorf(`invalid operation: %s. run "csvtk pivot --help" for help`, op))
+		}
+		if valueStr == "" && op != "count" {
+			checkError(fmt.Errorf("flag -v/--value needed for operation: %s", op))
+		}
+
+		ignore := getFlagBool(cmd, "ignore-non-numbers")
+		decimalWidth := getFlagNonNegativeInt(cmd, "decimal-width")
+		decimalFormat := fmt.Sprintf("%%.%df", decimalWidth)
+		na := getFlagString(cmd, "na")
+		margins := getFlagBool(cmd, "margins")
+		marginsLabel := getFlagString(cmd, "margins-label")
+		columnSep := getFlagString(cmd, "column-sep")
+		sortRows := getFlagBool(cmd, "sort-rows")
+		sortCols := getFlagBool(cmd, "sort-cols")
+
+		// for operations of "csvtk summary"
+		separater = getFlagString(cmd, "separater")
+		naValues = make(map[string]struct{}, 8)
+		for _, na := range getFlagStringSlice(cmd, "na-values") {
+			naValues[strings.ToLower(na)] = struct{}{}
+		}
+		fuStream, isStream := streamStats[op]
+		fuStream2, isStream2 := streamStats2[op]
+
+		fieldsR := strings.Split(rowsStr, ",")
+		fieldsC := strings.Split(colsStr, ",")
+		numFieldsR, numFieldsC := len(fieldsR), len(fieldsC)
+		fields := append(append([]string{}, fieldsR...), fieldsC...)
+		hasValue := valueStr != ""
+		if hasValue {
+			fields = append(fields, valueStr)
+		}
+		fieldStr := strings.Join(fields, ",")
+
+		outfh, err := wopenByConfig(config)
+		checkError(err)
+		defer outfh.Close()
+
+		writer := newRecordWriter(config, outfh)
+		defer func() {
+			writer.Flush()
+			checkError(writer.Error())
+		}()
+
+		file := files[0]
+		csvReader, err := newCSVReaderByConfig(config, file)
+
+		if err != nil {
+			if err == xopen.ErrNoContent {
+				if config.Verbose {
+					log.Warningf("csvtk pivot: skipping empty input file: %s", file)
+				}
+
+				writer.Flush()
+				checkError(writer.Error())
+				readerReport(&config, csvReader, file)
+				return
+			}
+			checkError(err)
+		}
+
+		csvReader.Read(ReadOption{
+			FieldStr: fieldStr,
+
+			DoNotAllowDuplicatedColumnName: true,
+		})
+
+		// values of cells, keys are row keys and column keys,
+		// and marginsKey for margins.
+		cells := make(map[string]map[string]*pivotCell, 1024)
+		rows := make([]string, 0, 1024)
+		cols := make([]string, 0, 64)
+		colsMap := make(map[string]struct{}, 64)
+		marginsKey := "_shenwei356_margins_"
+
+		var HeaderRow []string
+		var row, col, val string
+		var ok bool
+		var v float64
+		add := func(row, col, val string, v float64) {
+			var m map[string]*pivotCell
+			if m, ok = cells[row]; !ok {
+				m = make(map[string]*pivotCell, 64)
+				cells[row] = m
+			}
+			var cell *pivotCell
+			if cell, ok = m[col]; !ok {
+				cell = &pivotCell{}
+				if isStream || isStream2 {
+					cell.acc = newSummaryAccumulator()
+				}
+				m[col] = cell
+			}
+			switch {
+			case isStream:
+				cell.acc.addNumber(v)
+			case isStream2:
+				cell.acc.addString(val)
+			case isNumeric:
+				cell.numbers = append(cell.numbers, v)
+			default:
+				cell.values = append(cell.values, val)
+			}
+		}
+
+		checkFirstLine := true
+		for record := range csvReader.Ch {
+			if record.Err != nil {
+				checkError(record.Err)
+			}
+
+			if checkFirstLine {
+				checkFirstLine = false
+				if len(record.Fields) != len(fields) {
+					checkError(fmt.Errorf("fields should not be ranges or fuzzy fields: %s", fieldStr))
+				}
+				if !config.NoHeaderRow || record.IsHeaderRow {
+					HeaderRow = append([]string{}, record.Selected[:numFieldsR]...)
+					continue
+				}
+			}
+
+			row = strings.Join(record.Selected[:numFieldsR], "_shenwei356_")
+			col = strings.Join(record.Selected[numFieldsR:numFieldsR+numFieldsC], columnSep)
+			if hasValue {
+				val = record.Selected[numFieldsR+numFieldsC]
+			}
+
+			if isNumeric {
+				if !reDigitals.MatchString(val) {
+					if ignore {
+						continue
+					}
+					checkError(fmt.Errorf("[line %d] non-numeric value: %s, you can use flag -i/--ignore-non-numbers to skip these data", record.Line, val))
+				}
+				v, err = strconv.ParseFloat(removeComma(val), 64)
+				checkError(err)
+			}
+
+			if _, ok = cells[row]; !ok {
+				rows = append(rows, row)
+			}
+			if _, ok = colsMap[col]; !ok {
+				colsMap[col] = struct{}{}
+				cols = append(cols, col)
+			}
+
+			add(row, col, val, v)
+			if margins {
+				add(row, marginsKey, val, v)
+				add(marginsKey, col, val, v)
+				add(marginsKey, marginsKey, val, v)
+			}
+		}
+
+		if sortRows {
+			sort.Strings(rows)
+		}
+		if sortCols {
+			sort.Strings(cols)
+		}
+		if margins && len(rows) > 0 {
+			rows = append(rows, marginsKey)
+			cols = append(cols, marginsKey)
+		}
+
+		if !config.NoOutHeader {
+			if HeaderRow == nil {
+				HeaderRow = make([]string, numFieldsR)
+			}
+			for _, col = range cols {
+				if col == marginsKey {
+					HeaderRow = append(HeaderRow, marginsLabel)
+				} else {
+					HeaderRow = append(HeaderRow, col)
+				}
+			}
+			checkError(writer.Write(HeaderRow))
+		}
+
+		var items []string
+		var cell *pivotCell
+		for _, row = range rows {
+			items = make([]string, 0, numFieldsR+len(cols))
+			if row == marginsKey {
+				for i := 0; i < numFieldsR; i++ {
+					items = append(items, marginsLabel)
+				}
+			} else {
+				items = append(items, strings.Split(row, "_shenwei356_")...)
+			}
+
+			for _, col = range cols {
+				if cell, ok = cells[row][col]; !ok {
+					items = append(items, na)
+					continue
+				}
+				if isStream2 {
+					items = append(items, fuStream2(cell.acc))
+					continue
+				}
+				if isTextual {
+					items = append(items, fuStr(cell.values))
+					continue
+				}
+				if isStream {
+					v = fuStream(cell.acc)
+				} else {
+					if statsNeedSort[op] {
+						sort.Float64s(cell.numbers)
+					}
+					v = fuNum(cell.numbers)
+				}
+				if op == "countn" {
+					items = append(items, fmt.Sprintf("%.0f", v))
+				} else {
+					items = append(items, fmt.Sprintf(decimalFormat, v))
+				}
+			}
+			checkError(writer.Write(items))
+		}
+
+		readerReport(&config, csvReader, file)
+	},
+}
+
+// pivotCell stores values of a cell in a pivot table.
+type pivotCell struct {
+	acc     *summaryAccumulator // for streaming operations
+	numbers []float64           // for other numeric operations
+	values  []string            // for other textual operations
+}
+
+func init() {
+	RootCmd.AddCommand(pivotCmd)
+
+	pivotCmd.Flags().StringP("rows", "r", "", `row fields. e.g -r 1,2 or -r columnA,columnB`)
+	pivotCmd.Flags().StringP("columns", "c", "", `column fields. e.g -c 3 or -c columnC`)
+	pivotCmd.Flags().StringP("value", "v", "", `value field to aggregate. e.g -v 4 or -v columnD`)
+	pivotCmd.Flags().StringP("aggregate", "a", "sum", `aggregation operation, see "csvtk summary --help" for details`)
+	pivotCmd.Flags().BoolP("ignore-non-numbers", "i", false, `ignore non-numeric values like "NA" or "N/A" for numeric operations`)
+	pivotCmd.Flags().IntP("decimal-width", "w", 2, "limit floats to N decimal points")
+	pivotCmd.Flags().StringP("na", "", "", "content for filling empty cells")
+	pivotCmd.Flags().BoolP("margins", "m", false, `append a column and a row of aggregated values of all columns and all rows`)
+	pivotCmd.Flags().StringP("margins-label", "", "all", `label of the margins`)
+	pivotCmd.Flags().StringP("column-sep", "", "_", `separater for joining values of multiple column fields`)
+	pivotCmd.Flags().BoolP("sort-rows", "", false, `sort rows by row keys`)
+	pivotCmd.Flags().BoolP("sort-cols", "", false, `sort columns by column keys`)
+	pivotCmd.Flags().StringP("separater", "s", "; ", `separater for operations like "collapse" and "uniq"`)
+	pivotCmd.Flags().StringSliceP("na-values", "", []string{"", "NA", "N/A"}, `NA values for operation "countna", case ignored`)
+}
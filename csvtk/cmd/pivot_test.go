@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestPivot(t *testing.T) {
+	file := testFile(t, "p.csv", `region,year,q,sales
+east,2023,1,10
+west,2023,1,5
+east,2024,1,20
+east,2023,2,30
+west,2024,2,NA
+west,2024,1,15
+`)
+
+	cases := []struct {
+		args   []string
+		expect string
+	}{
+		{
+			[]string{"-r", "region", "-c", "year", "-v", "sales", "-a", "sum", "-i"},
+			"region,2023,2024\neast,40.00,20.00\nwest,5.00,15.00\n",
+		},
+		{
+			[]string{"-r", "1", "-c", "2", "-v", "4", "-a", "mean", "-i", "-m", "-w", "1"},
+			"region,2023,2024,all\neast,20.0,20.0,20.0\nwest,5.0,15.0,10.0\nall,15.0,17.5,16.0\n",
+		},
+		// margins are aggregated from raw values, not from values in cells
+		{
+			[]string{"-r", "year", "-c", "region", "-v", "sales", "-a", "median", "-i", "-m", "--margins-label", "total", "--sort-rows"},
+			"year,east,west,total\n2023,20.00,5.00,10.00\n2024,20.00,15.00,17.50\ntotal,20.00,10.00,15.00\n",
+		},
+		{
+			[]string{"-r", "region", "-c", "year,q", "-a", "count", "--sort-cols", "--na", "0"},
+			"region,2023_1,2023_2,2024_1,2024_2\neast,1,1,1,0\nwest,1,0,1,1\n",
+		},
+		{
+			[]string{"-r", "region", "-c", "year,q", "-a", "count", "--column-sep", "Q"},
+			"region,2023Q1,2024Q1,2023Q2,2024Q2\neast,1,1,1,\nwest,1,1,,1\n",
+		},
+		{
+			[]string{"-r", "region", "-c", "year", "-v", "sales", "-a", "countna", "--na-values", "10,na"},
+			"region,2023,2024\neast,1,0\nwest,0,1\n",
+		},
+		{
+			[]string{"-r", "region", "-c", "year", "-v", "sales", "-a", "collapse", "-s", "/"},
+			"region,2023,2024\neast,10/30,20\nwest,5,NA/15\n",
+		},
+	}
+	for _, c := range cases {
+		args := append([]string{"pivot", file}, c.args...)
+		if got := runCsvtk(t, args...); got != c.expect {
+			t.Errorf("csvtk %v:\nwant:\n%s\ngot:\n%s", args, c.expect, got)
+		}
+	}
+
+	// non-numeric values are not allowed for numeric operations without -i
+	args := []string{"pivot", file, "-r", "region", "-c", "year", "-v", "sales", "-a", "sum"}
+	if _, err := execCsvtkInSubprocess(t, args...); err == nil {
+		t.Errorf("csvtk %v: error expected", args)
+	}
+}
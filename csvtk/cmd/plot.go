@@ -90,6 +90,11 @@ func init() {
 func getPlotConfigs(cmd *cobra.Command) *plotConfigs {
 	config := new(plotConfigs)
 
+	// images are written with the output file directly by gonum/plot
+	if getFlagBool(cmd, "in-place") {
+		checkError(fmt.Errorf("flag --in-place is not supported by plot commands"))
+	}
+
 	config.dataFieldStr = getFlagString(cmd, "data-field")
 	if strings.Contains(config.dataFieldStr, ",") {
 		checkError(fmt.Errorf("only one field allowed for flag --data-field"))
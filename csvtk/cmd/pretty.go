@@ -187,7 +187,7 @@ Styles:
 			checkError(fmt.Errorf("the value of flag -x/--wrap-delimiter should be a single character: %s", wrapDelimiter))
 		}
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
@@ -206,19 +206,13 @@ Styles:
 		}
 
 		csvReader.Read(ReadOption{
-			FieldStr:      "1-",
-			ShowRowNumber: config.ShowRowNumber,
+			FieldStr:       "1-",
+			ShowRowNumber:  config.ShowRowNumber,
+			ShowProvenance: true,
 		})
 
 		styles := map[string]*stable.TableStyle{
-			"default": &stable.TableStyle{
-				Name:            "default",
-				LineBelowHeader: stable.LineStyle{"", "-", separator, ""},
-
-				HeaderRow: stable.RowStyle{"", separator, ""},
-				DataRow:   stable.RowStyle{"", separator, ""},
-				Padding:   "",
-			},
+			"default": prettyDefaultStyle(separator),
 			"plain":   stable.StylePlain,
 			"simple":  stable.StyleSimple,
 			"3line":   stable.StyleThreeLine,
@@ -394,6 +388,17 @@ Styles:
 	},
 }
 
+func prettyDefaultStyle(separator string) *stable.TableStyle {
+	return &stable.TableStyle{
+		Name:            "default",
+		LineBelowHeader: stable.LineStyle{"", "-", separator, ""},
+
+		HeaderRow: stable.RowStyle{"", separator, ""},
+		DataRow:   stable.RowStyle{"", separator, ""},
+		Padding:   "",
+	}
+}
+
 func init() {
 	RootCmd.AddCommand(prettyCmd)
 	prettyCmd.Flags().StringP("separator", "s", "   ", "fields/columns separator")
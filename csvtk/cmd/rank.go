@@ -0,0 +1,289 @@
+// Copyright © 2016-2023 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shenwei356/util/stringutil"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// rankCmd represents the rank command
+var rankCmd = &cobra.Command{
+	GroupID: "order",
+
+	Use:   "rank",
+	Short: "append rank columns computed over sort keys within groups",
+	Long: `append rank columns computed over sort keys within groups
+
+Records are ordered by sort keys (-k/--keys) within each group
+(-g/--groups), and columns of ranks are appended, with input records
+outputted in the original order.
+
+Rank methods (-m/--methods, multiple values supported):
+  rank          rank with gaps for ties, e.g., 1, 2, 2, 4
+  dense_rank    rank without gaps for ties, e.g., 1, 2, 2, 3
+  row_number    sequential number, ties are ordered by input order
+  percent_rank  (rank - 1) / (number of records in the group - 1)
+  ntile         bucket number from 1 to N (-n/--ntile), bucket sizes
+                differ by at most one, with larger buckets first
+
+Sort types of keys are the same as "csvtk sort":
+  - Default: alphabetical order  : -k 1
+  - n      : numeric order       : -k 1:n
+  - N      : natural order       : -k 1:N
+  - d      : sort by date        : -k 1:d
+  - u      : custom levels       : -k 1:u -L 1:levels.txt
+  - All sort types can be used with "r" for reversing the order, e.g., -k 1:nr
+
+Attention:
+  1. Fields of -k/--keys and -g/--groups should not be ranges or fuzzy fields.
+  2. All records are kept in memory.
+
+Examples:
+
+  # top 3 samples of each patient by score
+  $ csvtk rank -g patient -k score:nr data.csv \
+      | csvtk filter2 -f '$rank <= 3'
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if len(files) > 1 {
+			checkError(fmt.Errorf("no more than one file should be given"))
+		}
+		runtime.GOMAXPROCS(config.NumCPUs)
+
+		keys := getFlagStringSlice(cmd, "keys")
+		if len(keys) == 0 {
+			checkError(fmt.Errorf("flag -k/--keys needed"))
+		}
+		levels := getFlagStringSlice(cmd, "levels")
+		ignoreCase := getFlagBool(cmd, "ignore-case")
+		groupsStr := getFlagString(cmd, "groups")
+
+		methods := getFlagStringSlice(cmd, "methods")
+		if len(methods) == 0 {
+			checkError(fmt.Errorf("flag -m/--methods needed"))
+		}
+		for _, method := range methods {
+			switch method {
+			case "rank", "dense_rank", "row_number", "percent_rank", "ntile":
+			default:
+				checkError(fmt.Errorf("invalid rank method: %s. available: rank, dense_rank, row_number, percent_rank, ntile", method))
+			}
+		}
+		names := getFlagStringSlice(cmd, "names")
+		if len(names) == 0 {
+			names = methods
+		} else if len(names) != len(methods) {
+			checkError(fmt.Errorf("the number of column names (%d) does not match that of rank methods (%d)", len(names), len(methods)))
+		}
+		nTile := getFlagPositiveInt(cmd, "ntile")
+		decimalWidth := getFlagNonNegativeInt(cmd, "decimal-width")
+		decimalFormat := fmt.Sprintf("%%.%df", decimalWidth)
+
+		levelsMap := parseSortLevels(levels, ignoreCase, config.Verbose)
+		fieldsK, sortTypes := parseSortKeys(keys, levelsMap)
+		numFieldsK := len(fieldsK)
+
+		fields := fieldsK
+		if groupsStr != "" {
+			fields = append(fields, strings.Split(groupsStr, ",")...)
+		}
+		fieldStr := strings.Join(fields, ",")
+
+		// keys are compared by their positions in selected fields
+		sortTypes2 := make([]stringutil.SortType, len(sortTypes))
+		for i, t := range sortTypes {
+			sortTypes2[i] = stringutil.SortType{
+				Index:       i,
+				IgnoreCase:  ignoreCase,
+				Natural:     t.Natural,
+				Number:      t.Number,
+				Date:        t.Date,
+				Reverse:     t.Reverse,
+				UserDefined: t.UserDefined,
+				Levels:      t.Levels,
+			}
+		}
+
+		outfh, err := wopenByConfig(config)
+		checkError(err)
+		defer outfh.Close()
+
+		writer := newRecordWriter(config, outfh)
+		defer func() {
+			writer.Flush()
+			checkError(writer.Error())
+		}()
+
+		file := files[0]
+		csvReader, err := newCSVReaderByConfig(config, file)
+
+		if err != nil {
+			if err == xopen.ErrNoContent {
+				if config.Verbose {
+					log.Warningf("csvtk rank: skipping empty input file: %s", file)
+				}
+
+				writer.Flush()
+				checkError(writer.Error())
+				readerReport(&config, csvReader, file)
+				return
+			}
+			checkError(err)
+		}
+
+		csvReader.Read(ReadOption{
+			FieldStr: fieldStr,
+
+			DoNotAllowDuplicatedColumnName: true,
+		})
+
+		var records [][]string
+		groups := make(map[string]*rankList, 8)
+		groupsOrder := make([]string, 0, 8)
+
+		var group string
+		var list *rankList
+		var ok bool
+		checkFirstLine := true
+		for record := range csvReader.Ch {
+			if record.Err != nil {
+				checkError(record.Err)
+			}
+
+			if checkFirstLine {
+				checkFirstLine = false
+				if len(record.Fields) != len(fields) {
+					checkError(fmt.Errorf("fields should not be ranges or fuzzy fields: %s", fieldStr))
+				}
+				if !config.NoHeaderRow || record.IsHeaderRow {
+					if !config.NoOutHeader {
+						checkError(writer.Write(append(record.All, names...)))
+					}
+					continue
+				}
+			}
+
+			group = strings.Join(record.Selected[numFieldsK:], "_shenwei356_")
+			if list, ok = groups[group]; !ok {
+				list = &rankList{}
+				groups[group] = list
+				groupsOrder = append(groupsOrder, group)
+			}
+			list.list = append(list.list, stringutil.MultiKeyStringSlice{
+				SortTypes: &sortTypes2,
+				Value:     append([]string{}, record.Selected[:numFieldsK]...),
+			})
+			list.idx = append(list.idx, len(records))
+
+			records = append(records, record.All)
+		}
+
+		readerReport(&config, csvReader, file)
+
+		// ranks of all records
+		ranks := make([][]string, len(records))
+		var n, rank, denseRank, pos, q, r int
+		var percentRank float64
+		var items []string
+		for _, group = range groupsOrder {
+			list = groups[group]
+			sort.Stable(list)
+
+			n = len(list.idx)
+			q, r = n/nTile, n%nTile
+			rank, denseRank = 0, 0
+			for pos = 0; pos < n; pos++ {
+				if pos == 0 || list.list.Less(pos-1, pos) {
+					rank = pos + 1
+					denseRank++
+				}
+				if n > 1 {
+					percentRank = float64(rank-1) / float64(n-1)
+				} else {
+					percentRank = 0
+				}
+
+				items = make([]string, 0, len(methods))
+				for _, method := range methods {
+					switch method {
+					case "rank":
+						items = append(items, strconv.Itoa(rank))
+					case "dense_rank":
+						items = append(items, strconv.Itoa(denseRank))
+					case "row_number":
+						items = append(items, strconv.Itoa(pos+1))
+					case "percent_rank":
+						items = append(items, fmt.Sprintf(decimalFormat, percentRank))
+					case "ntile":
+						if pos < r*(q+1) {
+							items = append(items, strconv.Itoa(pos/(q+1)+1))
+						} else {
+							items = append(items, strconv.Itoa(r+(pos-r*(q+1))/q+1))
+						}
+					}
+				}
+				ranks[list.idx[pos]] = items
+			}
+		}
+
+		for i, record := range records {
+			checkError(writer.Write(append(record, ranks[i]...)))
+		}
+	},
+}
+
+// rankList is a list of sort keys of records in a group,
+// along with indexes of the records.
+type rankList struct {
+	list stringutil.MultiKeyStringSliceList
+	idx  []int
+}
+
+func (l *rankList) Len() int           { return len(l.idx) }
+func (l *rankList) Less(i, j int) bool { return l.list.Less(i, j) }
+func (l *rankList) Swap(i, j int) {
+	l.list[i], l.list[j] = l.list[j], l.list[i]
+	l.idx[i], l.idx[j] = l.idx[j], l.idx[i]
+}
+
+func init() {
+	RootCmd.AddCommand(rankCmd)
+
+	rankCmd.Flags().StringSliceP("keys", "k", []string{}, `sort keys (multiple values supported). sort type supported, "N" for natural order, "n" for number, "d" for date/time, "u" for user-defined order and "r" for reverse. e.g., "-k 1", "-k A:r", "-k 1:nr -k 2"`)
+	rankCmd.Flags().StringSliceP("levels", "L", []string{}, `user-defined level file (one level per line, multiple values supported). format: <field>:<level-file>.  e.g., "-k name:u -L name:level.txt"`)
+	rankCmd.Flags().BoolP("ignore-case", "i", false, "ignore-case")
+	rankCmd.Flags().StringP("groups", "g", "", `rank within groups of these fields. e.g -g 1,2 or -g columnA,columnB`)
+	rankCmd.Flags().StringSliceP("methods", "m", []string{"rank"}, `rank methods (multiple values supported), available: rank, dense_rank, row_number, percent_rank, ntile`)
+	rankCmd.Flags().StringSliceP("names", "", []string{}, `column names of ranks (default: names of rank methods)`)
+	rankCmd.Flags().IntP("ntile", "n", 4, `number of buckets for method "ntile"`)
+	rankCmd.Flags().IntP("decimal-width", "w", 4, `limit floats to N decimal points for method "percent_rank"`)
+}
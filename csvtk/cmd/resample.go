@@ -0,0 +1,484 @@
+// Copyright © 2016-2023 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/araddon/dateparse"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+	"gitlab.com/metakeule/fmtdate"
+)
+
+// resampleCmd represents the resample command
+var resampleCmd = &cobra.Command{
+	GroupID: "transform",
+
+	Use:   "resample",
+	Short: "resample time series into fixed intervals with aggregated values",
+	Long: `resample time series into fixed intervals with aggregated values
+
+Records are assigned to buckets of a fixed interval (-b/--bucket) according
+to the date/time of the time field (-k/--time-field), and values of
+fields are aggregated in each bucket with operations of "csvtk summary".
+Buckets are aligned to multiples of the interval since the Unix epoch in
+the local time zone (-z/--time-zone), e.g., midnights for "1d", while
+buckets of weeks are aligned to Mondays, i.e., multiples of the interval
+since 1970-01-05 (Monday). Buckets of days or weeks are stepped by calendar
+days, which might not be 24 hours due to daylight saving time.
+
+Empty buckets between the first and last buckets (of each group) are
+filled according to --fill:
+  na        fill with --na, except for counting operations which are 0
+  linear    linear interpolation of results of numeric operations
+  previous  results of the previous non-empty bucket
+  none      do not output empty buckets
+
+Intervals:
+  Integers with units of w (week), d (day), h (hour), m (minute) and
+  s (second), e.g., 1d, 6h, 15m, 1h30m.
+
+Output columns:
+  [groups], time field, field1:op1, field2:op2, ...
+
+  Buckets are labelled with their start time formatted with --format
+  in MS Excel (TM) syntax, type "csvtk fmtdate -h" for details.
+
+Attention:
+  1. Records with unparsed dates are not allowed unless
+     -i/--ignore-non-numbers is given. So are non-numeric values for
+     numeric operations.
+  2. Fields should not be ranges or fuzzy fields.
+  3. Groups are outputted in the order of first appearance.
+  4. The number of buckets (including empty ones) of a group is limited by
+     --max-buckets, to avoid exhausting memory for outlier dates.
+
+Examples:
+
+  $ csvtk resample -k time -b 1h -f temp:mean -f temp:max data.csv
+  $ csvtk resample -k time -b 15m -f value:mean -g sensor --fill linear data.csv
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if len(files) > 1 {
+			checkError(fmt.Errorf("no more than one file should be given"))
+		}
+		runtime.GOMAXPROCS(config.NumCPUs)
+
+		timezone := getFlagString(cmd, "time-zone")
+		if timezone != "" {
+			loc, err := time.LoadLocation(timezone)
+			if err != nil {
+				checkError(fmt.Errorf("setting time zone: %s", err))
+			}
+			time.Local = loc
+		}
+		outfmt := getFlagString(cmd, "format")
+
+		timeField := getFlagString(cmd, "time-field")
+		if timeField == "" {
+			checkError(fmt.Errorf("flag -k/--time-field needed"))
+		}
+		bucketStr := getFlagString(cmd, "bucket")
+		if bucketStr == "" {
+			checkError(fmt.Errorf("flag -b/--bucket needed"))
+		}
+		years, months, days, duration, err := parseDateDuration(bucketStr)
+		checkError(err)
+		if years != 0 || months != 0 {
+			checkError(fmt.Errorf("units of year and month are not supported for -b/--bucket: %s", bucketStr))
+		}
+		interval := time.Duration(days)*24*time.Hour + duration
+		if interval <= 0 {
+			checkError(fmt.Errorf("the interval of -b/--bucket should be positive: %s", bucketStr))
+		}
+		step := int64(interval / time.Second)
+		// buckets of days are aligned and stepped by the wall clock,
+		// i.e., calendar days in the time zone.
+		calendar := step%86400 == 0
+		// buckets of weeks start on Mondays, the Unix epoch is a Thursday.
+		var origin int64
+		if step%(7*86400) == 0 {
+			origin = 4 * 86400
+		}
+		maxBuckets := getFlagNonNegativeInt(cmd, "max-buckets")
+
+		fill := getFlagString(cmd, "fill")
+		switch fill {
+		case "na", "linear", "previous", "none":
+		default:
+			checkError(fmt.Errorf("invalid value of --fill: %s. available: na, linear, previous, none", fill))
+		}
+		na := getFlagString(cmd, "na")
+		ignore := getFlagBool(cmd, "ignore-non-numbers")
+		decimalWidth := getFlagNonNegativeInt(cmd, "decimal-width")
+		decimalFormat := fmt.Sprintf("%%.%df", decimalWidth)
+		groupsStr := getFlagString(cmd, "groups")
+
+		// for operations of "csvtk summary"
+		separater = getFlagString(cmd, "separater")
+		naValues = map[string]struct{}{"": {}, "na": {}, "n/a": {}}
+
+		// operations
+		type resampleOp struct {
+			field     string
+			op        string
+			idx       int // index of the field in data fields
+			fuNum     func([]float64) float64
+			fuStr     func([]string) string
+			isNumeric bool
+		}
+		opsStrs := getFlagStringSlice(cmd, "fields")
+		if len(opsStrs) == 0 {
+			checkError(fmt.Errorf("flag -f/--fields needed"))
+		}
+		ops := make([]*resampleOp, 0, len(opsStrs))
+		fieldsD := make([]string, 0, len(opsStrs))
+		fieldsDIdx := make(map[string]int, len(opsStrs))
+		for _, s := range opsStrs {
+			op := &resampleOp{op: "count"}
+			if i := strings.LastIndexByte(s, ':'); i >= 0 {
+				op.field, op.op = s[:i], s[i+1:]
+			} else {
+				op.field = s
+			}
+			if op.field == "" {
+				checkError(fmt.Errorf("invalid field: %s", s))
+			}
+			if op.fuNum, op.isNumeric = getStatFunc(op.op); !op.isNumeric {
+				var ok bool
+				if op.fuStr, ok = allStats2[op.op]; !ok {
+					checkError(fmt.Errorf(`invalid operation: %s. run "csvtk summary --help" for help`, op.op))
+				}
+			}
+			if idx, ok := fieldsDIdx[op.field]; ok {
+				op.idx = idx
+			} else {
+				op.idx = len(fieldsD)
+				fieldsDIdx[op.field] = op.idx
+				fieldsD = append(fieldsD, op.field)
+			}
+			ops = append(ops, op)
+		}
+
+		fields := []string{timeField}
+		var numFieldsG int
+		if groupsStr != "" {
+			fieldsG := strings.Split(groupsStr, ",")
+			numFieldsG = len(fieldsG)
+			fields = append(fields, fieldsG...)
+		}
+		fields = append(fields, fieldsD...)
+		fieldStr := strings.Join(fields, ",")
+
+		outfh, err := wopenByConfig(config)
+		checkError(err)
+		defer outfh.Close()
+
+		writer := newRecordWriter(config, outfh)
+		defer func() {
+			writer.Flush()
+			checkError(writer.Error())
+		}()
+
+		file := files[0]
+		csvReader, err := newCSVReaderByConfig(config, file)
+
+		if err != nil {
+			if err == xopen.ErrNoContent {
+				if config.Verbose {
+					log.Warningf("csvtk resample: skipping empty input file: %s", file)
+				}
+
+				writer.Flush()
+				checkError(writer.Error())
+				readerReport(&config, csvReader, file)
+				return
+			}
+			checkError(err)
+		}
+
+		csvReader.Read(ReadOption{
+			FieldStr: fieldStr,
+
+			DoNotAllowDuplicatedColumnName: true,
+		})
+
+		// values of data fields in a bucket
+		type resampleBucket struct {
+			numbers [][]float64
+			values  [][]string
+		}
+		// group -> bucket start (seconds since the Unix epoch, of the wall clock
+		// for calendar days) -> bucket
+		data := make(map[string]map[int64]*resampleBucket, 8)
+		groups := make([]string, 0, 8)
+
+		var HeaderRow []string
+		var group, s string
+		var t time.Time
+		var offset int
+		var key int64
+		var v float64
+		var buckets map[int64]*resampleBucket
+		var bucket *resampleBucket
+		var i int
+		var ok bool
+		checkFirstLine := true
+		for record := range csvReader.Ch {
+			if record.Err != nil {
+				checkError(record.Err)
+			}
+
+			if checkFirstLine {
+				checkFirstLine = false
+				if len(record.Fields) != len(fields) {
+					checkError(fmt.Errorf("fields should not be ranges or fuzzy fields: %s", fieldStr))
+				}
+				if !config.NoHeaderRow || record.IsHeaderRow {
+					HeaderRow = append([]string{}, record.Selected[1:1+numFieldsG]...)
+					HeaderRow = append(HeaderRow, record.Selected[0])
+					continue
+				}
+			}
+
+			t, err = dateparse.ParseLocal(record.Selected[0])
+			if err != nil {
+				if ignore {
+					continue
+				}
+				checkError(fmt.Errorf("[line %d] failed to parse date: %s, you can use flag -i/--ignore-non-numbers to skip these records", record.Line, record.Selected[0]))
+			}
+			_, offset = t.Zone()
+			key = t.Unix() + int64(offset)
+			key = key - (((key-origin)%step)+step)%step
+			if !calendar {
+				key -= int64(offset)
+			}
+
+			group = strings.Join(record.Selected[1:1+numFieldsG], "_shenwei356_")
+			if buckets, ok = data[group]; !ok {
+				buckets = make(map[int64]*resampleBucket, 1024)
+				data[group] = buckets
+				groups = append(groups, group)
+			}
+			if bucket, ok = buckets[key]; !ok {
+				bucket = &resampleBucket{
+					numbers: make([][]float64, len(fieldsD)),
+					values:  make([][]string, len(fieldsD)),
+				}
+				buckets[key] = bucket
+			}
+
+			for i, s = range record.Selected[1+numFieldsG:] {
+				bucket.values[i] = append(bucket.values[i], s)
+				if !reDigitals.MatchString(s) {
+					continue
+				}
+				v, err = strconv.ParseFloat(removeComma(s), 64)
+				checkError(err)
+				bucket.numbers[i] = append(bucket.numbers[i], v)
+			}
+
+			for _, op := range ops {
+				if op.isNumeric && len(bucket.values[op.idx]) != len(bucket.numbers[op.idx]) && !ignore {
+					checkError(fmt.Errorf("[line %d] non-numeric value: %s, you can use flag -i/--ignore-non-numbers to skip these data", record.Line, record.Selected[1+numFieldsG+op.idx]))
+				}
+			}
+		}
+
+		readerReport(&config, csvReader, file)
+
+		if !config.NoOutHeader {
+			if HeaderRow == nil {
+				HeaderRow = make([]string, numFieldsG+1)
+			}
+			for _, op := range ops {
+				HeaderRow = append(HeaderRow, op.field+":"+op.op)
+			}
+			checkError(writer.Write(HeaderRow))
+		}
+
+		// labels of buckets
+		label := func(key int64) string {
+			if calendar {
+				u := time.Unix(key, 0).UTC()
+				return fmtdate.Format(outfmt, time.Date(u.Year(), u.Month(), u.Day(), 0, 0, 0, 0, time.Local))
+			}
+			return fmtdate.Format(outfmt, time.Unix(key, 0))
+		}
+
+		var keys []int64
+		var start, end int64
+		var results [][]float64 // numeric results of buckets, NaN for empty buckets
+		var j int
+		var items []string
+		for _, group = range groups {
+			buckets = data[group]
+
+			keys = keys[:0]
+			first := true
+			for key = range buckets {
+				if first || key < start {
+					start = key
+				}
+				if first || key > end {
+					end = key
+				}
+				first = false
+			}
+			if fill == "none" { // only non-empty buckets
+				for key = range buckets {
+					keys = append(keys, key)
+				}
+				sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+			} else {
+				if n := (end-start)/step + 1; maxBuckets > 0 && n > int64(maxBuckets) {
+					checkError(fmt.Errorf("too many buckets (%d) from %s to %s, please use a larger interval, check outlier dates, or increase --max-buckets",
+						n, label(start), label(end)))
+				}
+				for key = start; key <= end; key += step {
+					keys = append(keys, key)
+				}
+			}
+
+			// numeric results
+			results = make([][]float64, len(ops))
+			for i, op := range ops {
+				if !op.isNumeric {
+					continue
+				}
+				results[i] = make([]float64, len(keys))
+				for j, key = range keys {
+					if bucket, ok = buckets[key]; !ok {
+						results[i][j] = math.NaN()
+						continue
+					}
+					if statsNeedSort[op.op] {
+						sort.Float64s(bucket.numbers[op.idx])
+					}
+					if len(bucket.numbers[op.idx]) == 0 && op.op != "countn" {
+						results[i][j] = math.NaN()
+						continue
+					}
+					results[i][j] = op.fuNum(bucket.numbers[op.idx])
+				}
+				if fill == "linear" {
+					interpolateLinear(results[i], func(j int) bool {
+						_, ok := buckets[keys[j]]
+						return !ok
+					})
+				}
+			}
+
+			var last []string // results of the previous non-empty bucket
+			for j, key = range keys {
+				bucket, ok = buckets[key]
+				if !ok && fill == "none" {
+					continue
+				}
+
+				items = make([]string, 0, numFieldsG+1+len(ops))
+				if numFieldsG > 0 {
+					items = append(items, strings.Split(group, "_shenwei356_")...)
+				}
+				items = append(items, label(key))
+
+				if !ok && fill == "previous" {
+					items = append(items, last...)
+					checkError(writer.Write(items))
+					continue
+				}
+
+				for i, op := range ops {
+					switch {
+					case !ok && (op.op == "count" || op.op == "countn" || op.op == "countunique" ||
+						op.op == "countuniq" || op.op == "countna"):
+						items = append(items, "0")
+					case op.isNumeric && math.IsNaN(results[i][j]) && (ok || fill == "linear"):
+						items = append(items, na)
+					case op.isNumeric && (ok || fill == "linear"):
+						if op.op == "countn" {
+							items = append(items, fmt.Sprintf("%.0f", results[i][j]))
+						} else {
+							items = append(items, fmt.Sprintf(decimalFormat, results[i][j]))
+						}
+					case ok:
+						items = append(items, op.fuStr(bucket.values[op.idx]))
+					default:
+						items = append(items, na)
+					}
+				}
+				if ok {
+					last = items[numFieldsG+1:]
+				}
+				checkError(writer.Write(items))
+			}
+		}
+	},
+}
+
+// interpolateLinear fills values of missing items with linear interpolation
+// of their nearest non-missing neighbors. Leading and trailing missing items
+// are kept unchanged.
+func interpolateLinear(values []float64, missing func(int) bool) {
+	prev := -1
+	for i := range values {
+		if missing(i) || math.IsNaN(values[i]) {
+			continue
+		}
+		if prev >= 0 && i-prev > 1 {
+			d := (values[i] - values[prev]) / float64(i-prev)
+			for k := prev + 1; k < i; k++ {
+				if missing(k) {
+					values[k] = values[prev] + d*float64(k-prev)
+				}
+			}
+		}
+		prev = i
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(resampleCmd)
+
+	resampleCmd.Flags().StringP("time-field", "k", "", `field of date/time. e.g -k 1 or -k time`)
+	resampleCmd.Flags().StringP("bucket", "b", "", `interval of buckets, e.g., 1d, 6h, 15m, 1h30m`)
+	resampleCmd.Flags().StringSliceP("fields", "f", []string{}, `operations on fields in the format of field:op, e.g., -f temp:mean -f temp:max. type "csvtk summary -h" for available operations`)
+	resampleCmd.Flags().StringP("groups", "g", "", `resample within groups of these fields. e.g -g 1,2 or -g columnA,columnB`)
+	resampleCmd.Flags().StringP("fill", "", "na", `filling method for empty buckets: na, linear, previous, none`)
+	resampleCmd.Flags().StringP("na", "", "NA", "content for filling empty buckets")
+	resampleCmd.Flags().BoolP("ignore-non-numbers", "i", false, `ignore records with unparsed dates, and non-numeric values for numeric operations`)
+	resampleCmd.Flags().IntP("decimal-width", "w", 2, "limit floats to N decimal points")
+	resampleCmd.Flags().StringP("time-zone", "z", "", `timezone aka "Asia/Shanghai" or "America/Los_Angeles" formatted time-zone, type "csvtk fmtdate -h" for details`)
+	resampleCmd.Flags().StringP("format", "", "YYYY-MM-DD hh:mm:ss", `date format of bucket labels in MS Excel (TM) syntax, type "csvtk fmtdate -h" for details`)
+	resampleCmd.Flags().StringP("separater", "s", "; ", `separater for operations like "collapse" and "uniq"`)
+	resampleCmd.Flags().IntP("max-buckets", "", 1000000, `maximum number of buckets of a group, 0 for no limit`)
+}
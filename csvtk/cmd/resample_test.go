@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestInterpolateLinear(t *testing.T) {
+	nan := math.NaN()
+	cases := []struct {
+		values  []float64
+		missing []bool // nil for treating NaN as missing
+		expect  []float64
+	}{
+		{
+			values: []float64{},
+			expect: []float64{},
+		},
+		{
+			values: []float64{1, nan, 3},
+			expect: []float64{1, 2, 3},
+		},
+		{
+			values: []float64{0, nan, nan, nan, 8},
+			expect: []float64{0, 2, 4, 6, 8},
+		},
+		{
+			values: []float64{10, nan, 0, nan, nan, 3},
+			expect: []float64{10, 5, 0, 1, 2, 3},
+		},
+		// leading and trailing missing values are kept
+		{
+			values: []float64{nan, 1, nan, 3, nan},
+			expect: []float64{nan, 1, 2, 3, nan},
+		},
+		{
+			values: []float64{nan, nan},
+			expect: []float64{nan, nan},
+		},
+		// NaN of non-missing items, e.g., mean of non-numeric values,
+		// are kept and not used for interpolation
+		{
+			values:  []float64{1, nan, nan, 4},
+			missing: []bool{false, false, true, false},
+			expect:  []float64{1, nan, 3, 4},
+		},
+		// only missing items are interpolated
+		{
+			values:  []float64{1, 5, nan, 3},
+			missing: []bool{false, false, true, false},
+			expect:  []float64{1, 5, 4, 3},
+		},
+	}
+
+	for _, c := range cases {
+		values := append([]float64{}, c.values...)
+		missing := func(i int) bool {
+			if c.missing == nil {
+				return math.IsNaN(c.values[i])
+			}
+			return c.missing[i]
+		}
+		interpolateLinear(values, missing)
+
+		ok := len(values) == len(c.expect)
+		for i := 0; ok && i < len(values); i++ {
+			if math.IsNaN(c.expect[i]) {
+				ok = math.IsNaN(values[i])
+			} else {
+				ok = math.Abs(values[i]-c.expect[i]) < 1e-12
+			}
+		}
+		if !ok {
+			t.Errorf("interpolateLinear(%v, missing: %v): want %v, got %v", c.values, c.missing, c.expect, values)
+		}
+	}
+}
+
+func TestResample(t *testing.T) {
+	file := testFile(t, "ts.csv", `time,v
+2024-01-01 10:00:00,1
+2024-01-07 23:00:00,2
+2024-01-08,3
+2024-01-22,4
+`)
+
+	cases := []struct {
+		args   []string
+		expect string
+	}{
+		// buckets of weeks start on Mondays, e.g., 2024-01-01
+		{
+			[]string{"-b", "1w", "-f", "v:sum"},
+			"time,v:sum\n2024-01-01,3.00\n2024-01-08,3.00\n2024-01-15,NA\n2024-01-22,4.00\n",
+		},
+		{
+			[]string{"-b", "2w", "-f", "v:sum,v:count"},
+			"time,v:sum,v:count\n2023-12-25,3.00,2\n2024-01-08,3.00,1\n2024-01-22,4.00,1\n",
+		},
+		{
+			[]string{"-b", "1d", "-f", "v:max", "--fill", "none"},
+			"time,v:max\n2024-01-01,1.00\n2024-01-07,2.00\n2024-01-08,3.00\n2024-01-22,4.00\n",
+		},
+		{
+			[]string{"-b", "1w", "-f", "v:mean", "--fill", "linear", "-w", "1"},
+			"time,v:mean\n2024-01-01,1.5\n2024-01-08,3.0\n2024-01-15,3.5\n2024-01-22,4.0\n",
+		},
+		{
+			[]string{"-b", "1w", "-f", "v:sum", "--fill", "previous"},
+			"time,v:sum\n2024-01-01,3.00\n2024-01-08,3.00\n2024-01-15,3.00\n2024-01-22,4.00\n",
+		},
+	}
+	for _, c := range cases {
+		args := append([]string{"resample", file, "-k", "time", "-z", "UTC", "--format", "YYYY-MM-DD"}, c.args...)
+		got := runCsvtk(t, args...)
+		if got != c.expect {
+			t.Errorf("csvtk resample %s:\nwant %q\ngot  %q", strings.Join(c.args, " "), c.expect, got)
+		}
+	}
+
+	stderr, err := execCsvtkInSubprocess(t, "resample", file, "-k", "time", "-z", "UTC", "-b", "12h", "-f", "v:sum", "--max-buckets", "10")
+	if err == nil || !strings.Contains(stderr, "too many buckets") {
+		t.Errorf("csvtk resample --max-buckets 10: want error of too many buckets, got %v: %s", err, stderr)
+	}
+}
@@ -66,8 +66,14 @@ Attention:
      original format as needed.
   9. csvtk writes gzip files very fast, much faster than the multi-threaded pigz,
      therefore there's no need to pipe the result to gzip/pigz.
-     csvtk also supports reading and writing xz (.xz), zstd (.zst) and Bzip2 (.bz2) formats.
+     csvtk also supports reading and writing xz (.xz), zstd (.zst), Bzip2 (.bz2) and LZ4 (.lz4) formats,
+     the output compression format is inferred from the suffix of the output file,
+     and the compression level can be set with "--compress-level".
  10. Less than half of the subcommands support >1 file.
+ 11. Input files can also be directories or glob patterns (quoted to avoid shell expansion),
+     where "**" matches zero or more directories, e.g., 'data/**/part-*.tsv.gz'.
+     Use "--recursive" to search files in directories recursively, and
+     "--file-include" and "--file-exclude" to filter files by base names.
 
 Environment variables for frequently used global flags:
 
@@ -155,13 +161,26 @@ func init() {
 	RootCmd.PersistentFlags().BoolP("out-tabs", "T", false, `specifies that the output is delimited with tabs. Overrides "-D"`)
 	RootCmd.PersistentFlags().BoolP("no-header-row", "H", false, `specifies that the input CSV file does not have header row`)
 	RootCmd.PersistentFlags().BoolP("delete-header", "U", false, `do not output header row`)
-	RootCmd.PersistentFlags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+	RootCmd.PersistentFlags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz/.xz/.zst/.bz2/.lz4 for compressed out)`)
+	RootCmd.PersistentFlags().BoolP("in-place", "", false, `edit the input file in place, only one input file is allowed. exclusive with "-o"`)
+	RootCmd.PersistentFlags().StringP("backup-suffix", "", "", `backup the input file with this suffix before editing it in place, for --in-place`)
+	RootCmd.PersistentFlags().IntP("compress-level", "", -1, `compression level of output files, -1 for the default level of each format. `+
+		`gzip: 0-9 (0 for no compression), zstd: 1-4, bzip2: 1-9, lz4: 0-9. gzip compression uses the threads set by "-j"`)
+	RootCmd.PersistentFlags().StringP("out-format", "", "csv", `output format, available values: csv, tsv, ndjson (jsonl), markdown (md), pretty, xlsx. `+
+		`csv respects "-D" and "-T". For ndjson and pretty, the first row is treated as the header row unless "-H" or "-U" is given`)
 
 	RootCmd.PersistentFlags().BoolP("show-row-number", "Z", false, `show row number as the first column, with header row skipped`)
+	RootCmd.PersistentFlags().BoolP("show-filename", "", false, `show the source file of each record as a leading column ("file")`)
+	RootCmd.PersistentFlags().BoolP("filename-base", "", false, `only show the base name of the source file, for --show-filename`)
+	RootCmd.PersistentFlags().BoolP("filename-trim-ext", "", false, `trim the extension of the source file, for --show-filename`)
+	RootCmd.PersistentFlags().BoolP("show-line-number", "", false, `show the line number of each record in the source file as a leading column ("line")`)
 
 	RootCmd.PersistentFlags().BoolP("ignore-empty-row", "E", false, `ignore empty rows`)
 	RootCmd.PersistentFlags().BoolP("ignore-illegal-row", "I", false, `ignore illegal rows. You can also use 'csvtk fix' to fix files with different numbers of columns in rows`)
 	RootCmd.PersistentFlags().StringP("infile-list", "X", "", "file of input files list (one file per line), if given, they are appended to files from cli arguments. Note that less than half of the subcommands support >1 file.")
+	RootCmd.PersistentFlags().BoolP("recursive", "", false, "search files in directories recursively, for directories given as input files")
+	RootCmd.PersistentFlags().StringSliceP("file-include", "", []string{}, `only include files with base names matching these glob patterns, for input directories and glob patterns. e.g., --file-include "*.tsv.gz"`)
+	RootCmd.PersistentFlags().StringSliceP("file-exclude", "", []string{}, `exclude files with base names matching these glob patterns, for input directories and glob patterns`)
 
 	RootCmd.PersistentFlags().BoolP("version", "V", false, "print version information")
 
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// envTestArgs is the environment variable of arguments for running csvtk
+// in a subprocess, separated by "\x1f".
+const envTestArgs = "CSVTK_TEST_ARGS"
+
+func TestMain(m *testing.M) {
+	if args := os.Getenv(envTestArgs); args != "" {
+		RootCmd.SetArgs(strings.Split(args, "\x1f"))
+		Execute()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// execCsvtkInSubprocess runs a csvtk command in a subprocess, for commands
+// exiting on errors, and returns the standard error output and the error.
+func execCsvtkInSubprocess(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), envTestArgs+"="+strings.Join(args, "\x1f"))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stderr.String(), err
+}
+
+// runCsvtk runs a csvtk command with the arguments, and returns the output.
+// All flags are reset to their default values before running.
+func runCsvtk(t *testing.T, args ...string) string {
+	t.Helper()
+	return runCsvtkWithOutFile(t, filepath.Join(t.TempDir(), "out"), args...)
+}
+
+// runCsvtkWithOutFile is similar to runCsvtk, but the output is written
+// into the given file, e.g., with a suffix for the image format of plots.
+func runCsvtkWithOutFile(t *testing.T, outFile string, args ...string) string {
+	t.Helper()
+	execCsvtk(t, append(args, "-o", outFile)...)
+
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("csvtk %s: %s", strings.Join(args, " "), err)
+	}
+	return string(data)
+}
+
+// execCsvtk runs a csvtk command with the arguments, for commands
+// writing multiple output files, e.g., split.
+// All flags are reset to their default values before running.
+func execCsvtk(t *testing.T, args ...string) {
+	t.Helper()
+	resetFlags(RootCmd)
+
+	RootCmd.SetArgs(args)
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatalf("csvtk %s: %s", strings.Join(args, " "), err)
+	}
+}
+
+// readOutFiles returns contents of all (decompressed) files in a directory,
+// with paths relative to the directory as the keys.
+func readOutFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		fh, err := xopen.Ropen(path)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+		data, err := io.ReadAll(fh)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[rel] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// resetFlags resets flags of a command and its subcommands to the default values,
+// as cobra keeps values of flags between executions.
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		if v, ok := f.Value.(pflag.SliceValue); ok {
+			values := []string{}
+			if s := strings.Trim(f.DefValue, "[]"); s != "" {
+				values = strings.Split(s, ",")
+			}
+			v.Replace(values)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, c := range cmd.Commands() {
+		resetFlags(c)
+	}
+}
+
+// testFile writes the data into a file in a temporary directory,
+// and returns the path.
+func testFile(t *testing.T, name string, data string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
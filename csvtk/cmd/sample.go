@@ -21,7 +21,6 @@
 package cmd
 
 import (
-	"encoding/csv"
 	"fmt"
 	"math/rand"
 	"runtime"
@@ -59,20 +58,11 @@ var sampleCmd = &cobra.Command{
 		seed := getFlagInt64(cmd, "rand-seed")
 		_rand := rand.New(rand.NewSource(seed))
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
-		writer := csv.NewWriter(outfh)
-		if config.OutTabs || config.Tabs {
-			if config.OutDelimiter == ',' {
-				writer.Comma = '\t'
-			} else {
-				writer.Comma = config.OutDelimiter
-			}
-		} else {
-			writer.Comma = config.OutDelimiter
-		}
+		writer := newRecordWriter(config, outfh)
 		defer func() {
 			writer.Flush()
 			checkError(writer.Error())
@@ -92,8 +82,9 @@ var sampleCmd = &cobra.Command{
 			}
 
 			csvReader.Read(ReadOption{
-				FieldStr:      "1-",
-				ShowRowNumber: printLineNumber || config.ShowRowNumber,
+				FieldStr:       "1-",
+				ShowRowNumber:  printLineNumber || config.ShowRowNumber,
+				ShowProvenance: true,
 			})
 
 			checkFirstLine := true
@@ -0,0 +1,350 @@
+// Copyright © 2016-2023 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+	"gonum.org/v1/gonum/stat"
+)
+
+// scaleCmd represents the scale command
+var scaleCmd = &cobra.Command{
+	GroupID: "edit",
+
+	Use:   "scale",
+	Short: "normalize and scale numeric fields in place (groupby group fields)",
+	Long: `normalize and scale numeric fields in place (groupby group fields)
+
+Methods (-m/--method):
+  Computed from all values of a field (in each group):
+    zscore   (x - mean) / stdev, with sample standard deviation
+    minmax   (x - min) / (max - min)
+    robust   (x - median) / IQR
+    center   x - mean
+    rank     (average rank - 1) / (n - 1), in the range of [0, 1]
+  Computed from each value, records are processed in streaming mode:
+    log      natural logarithm
+    log2     base-2 logarithm
+    log10    base-10 logarithm
+    log1p    log(1 + x)
+    sqrt     square root
+
+NA values:
+  Cells of NA values (--na-values, case ignored) are kept unchanged
+  and excluded from statistics. Other non-numeric values are not allowed
+  unless -i/--ignore-non-numbers is given, which are also kept unchanged.
+  Undefined results, e.g., log of non-positive values, or zscore of
+  a field with all values identical, are outputted as --na.
+
+Attention:
+  1. Fields of -g/--groups should not be open ranges (e.g., 3-) or
+     unselected fields, and -F/--fuzzy-fields is not supported along
+     with -g/--groups.
+  2. All records are kept in memory for methods computed from all values.
+
+Examples:
+
+  $ csvtk scale -f 2-4 -m zscore data.csv
+  $ csvtk scale -f expression -m center -g gene data.csv
+  $ csvtk scale -f count -m log1p data.csv
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if len(files) > 1 {
+			checkError(fmt.Errorf("no more than one file should be given"))
+		}
+		runtime.GOMAXPROCS(config.NumCPUs)
+
+		fieldStr := getFlagString(cmd, "fields")
+		if fieldStr == "" {
+			checkError(fmt.Errorf("flag -f (--fields) needed"))
+		}
+		fuzzyFields := getFlagBool(cmd, "fuzzy-fields")
+		groupsStr := getFlagString(cmd, "groups")
+		if fuzzyFields && groupsStr != "" {
+			checkError(fmt.Errorf("flag -F/--fuzzy-fields is not supported along with -g/--groups"))
+		}
+
+		method := getFlagString(cmd, "method")
+		var streaming bool
+		switch method {
+		case "zscore", "minmax", "robust", "center", "rank":
+		case "log", "log2", "log10", "log1p", "sqrt":
+			streaming = true
+		default:
+			checkError(fmt.Errorf("invalid method: %s. run \"csvtk scale --help\" for help", method))
+		}
+
+		ignore := getFlagBool(cmd, "ignore-non-numbers")
+		na := getFlagString(cmd, "na")
+		naValues := make(map[string]struct{}, 8)
+		for _, v := range getFlagStringSlice(cmd, "na-values") {
+			naValues[strings.ToLower(v)] = struct{}{}
+		}
+		decimalWidth := getFlagNonNegativeInt(cmd, "decimal-width")
+		decimalFormat := fmt.Sprintf("%%.%df", decimalWidth)
+
+		format := func(v float64) string {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return na
+			}
+			return fmt.Sprintf(decimalFormat, v)
+		}
+
+		var numFieldsG int
+		numFields := -1 // expected number of fields, unknown for open ranges of fields to scale
+		if groupsStr != "" {
+			numFieldsG = countFields(groupsStr, false)
+			if numFieldsG < 0 {
+				checkError(fmt.Errorf("open ranges or unselected fields are not supported for -g/--groups: %s", groupsStr))
+			}
+			fieldStr = groupsStr + "," + fieldStr
+			numFields = countFields(fieldStr, false)
+		}
+
+		outfh, err := wopenByConfig(config)
+		checkError(err)
+		defer outfh.Close()
+
+		writer := newRecordWriter(config, outfh)
+		defer func() {
+			writer.Flush()
+			checkError(writer.Error())
+		}()
+
+		file := files[0]
+		csvReader, err := newCSVReaderByConfig(config, file)
+
+		if err != nil {
+			if err == xopen.ErrNoContent {
+				if config.Verbose {
+					log.Warningf("csvtk scale: skipping empty input file: %s", file)
+				}
+
+				writer.Flush()
+				checkError(writer.Error())
+				readerReport(&config, csvReader, file)
+				return
+			}
+			checkError(err)
+		}
+
+		csvReader.Read(ReadOption{
+			FieldStr:    fieldStr,
+			FuzzyFields: fuzzyFields,
+		})
+
+		// values to scale, keys are group keys and column indexes
+		type scaleData struct {
+			values  []float64
+			records []int // indexes of records
+		}
+		data := make(map[string]*scaleData, 64)
+		keys := make([]string, 0, 64)
+
+		var records [][]string
+
+		var group, key, s string
+		var v float64
+		var values *scaleData
+		var ok bool
+		checkFirstLine := true
+		for record := range csvReader.Ch {
+			if record.Err != nil {
+				checkError(record.Err)
+			}
+
+			if checkFirstLine {
+				checkFirstLine = false
+				if numFields >= 0 && len(record.Fields) != numFields {
+					checkError(fmt.Errorf("the number of matched fields (%d) does not match that of given fields (%d), please check duplicated column names: %s", len(record.Fields), numFields, fieldStr))
+				}
+				if numFieldsG > 0 && len(record.Fields) <= numFieldsG {
+					checkError(fmt.Errorf("no fields to scale: %s", fieldStr))
+				}
+				if !config.NoHeaderRow || record.IsHeaderRow {
+					if !config.NoOutHeader {
+						checkError(writer.Write(record.All))
+					}
+					continue
+				}
+			}
+
+			if numFieldsG > 0 {
+				group = strings.Join(record.Selected[:numFieldsG], "_shenwei356_")
+			}
+
+			for _, f := range record.Fields[numFieldsG:] {
+				s = record.All[f-1]
+				if _, ok = naValues[strings.ToLower(s)]; ok {
+					continue
+				}
+				if !reDigitals.MatchString(s) {
+					if ignore {
+						continue
+					}
+					checkError(fmt.Errorf("[line %d] non-numeric value: %s, you can use flag -i/--ignore-non-numbers to skip these data", record.Line, s))
+				}
+				v, err = strconv.ParseFloat(removeComma(s), 64)
+				checkError(err)
+
+				if streaming {
+					record.All[f-1] = format(scaleValue(method, v))
+					continue
+				}
+
+				key = group + "_shenwei356_" + strconv.Itoa(f)
+				if values, ok = data[key]; !ok {
+					values = &scaleData{}
+					data[key] = values
+					keys = append(keys, key)
+				}
+				values.values = append(values.values, v)
+				values.records = append(values.records, len(records))
+			}
+
+			if streaming {
+				checkError(writer.Write(record.All))
+				continue
+			}
+			records = append(records, record.All)
+		}
+
+		readerReport(&config, csvReader, file)
+
+		if streaming {
+			return
+		}
+
+		var f int
+		var scaled []float64
+		for _, key = range keys {
+			values = data[key]
+			f, _ = strconv.Atoi(key[strings.LastIndex(key, "_")+1:])
+
+			scaled = scaleValues(method, values.values)
+			for i, r := range values.records {
+				records[r][f-1] = format(scaled[i])
+			}
+		}
+
+		for _, record := range records {
+			checkError(writer.Write(record))
+		}
+	},
+}
+
+// scaleValue transforms a value with methods computed from each value.
+func scaleValue(method string, v float64) float64 {
+	switch method {
+	case "log", "log2", "log10":
+		if v <= 0 {
+			return math.NaN()
+		}
+		switch method {
+		case "log2":
+			return math.Log2(v)
+		case "log10":
+			return math.Log10(v)
+		}
+		return math.Log(v)
+	case "log1p":
+		if v <= -1 {
+			return math.NaN()
+		}
+		return math.Log1p(v)
+	case "sqrt":
+		return math.Sqrt(v)
+	}
+	return v
+}
+
+// scaleValues transforms values with methods computed from all values.
+// Returned values are NaN for undefined results.
+func scaleValues(method string, values []float64) []float64 {
+	n := len(values)
+	result := make([]float64, n)
+
+	var a, b float64 // x' = (x - a) / b
+	switch method {
+	case "zscore":
+		a, b = stat.MeanStdDev(values, nil)
+	case "minmax":
+		min, max := values[0], values[0]
+		for _, v := range values {
+			if v < min {
+				min = v
+			}
+			if v > max {
+				max = v
+			}
+		}
+		a, b = min, max-min
+	case "robust":
+		sorted := append([]float64{}, values...)
+		sort.Float64s(sorted)
+		a = median(sorted)
+		b = percentileValue(sorted, 0.75) - percentileValue(sorted, 0.25)
+	case "center":
+		a, b = stat.Mean(values, nil), 1
+	case "rank":
+		ranks := rankValues(values)
+		for i, r := range ranks {
+			if n > 1 {
+				result[i] = (r - 1) / float64(n-1)
+			} else {
+				result[i] = math.NaN()
+			}
+		}
+		return result
+	}
+
+	for i, v := range values {
+		if b == 0 || math.IsNaN(b) {
+			result[i] = math.NaN()
+		} else {
+			result[i] = (v - a) / b
+		}
+	}
+	return result
+}
+
+func init() {
+	RootCmd.AddCommand(scaleCmd)
+	scaleCmd.Flags().StringP("fields", "f", "1", `select only these fields. e.g -f 1,2 or -f columnA,columnB`)
+	scaleCmd.Flags().BoolP("fuzzy-fields", "F", false, `using fuzzy fields, e.g., -F -f "*name" or -F -f "id123*"`)
+	scaleCmd.Flags().StringP("groups", "g", "", `compute statistics within groups of these fields. e.g -g 1,2 or -g columnA,columnB`)
+	scaleCmd.Flags().StringP("method", "m", "zscore", `method: zscore, minmax, robust, center, rank, log, log2, log10, log1p, sqrt`)
+	scaleCmd.Flags().BoolP("ignore-non-numbers", "i", false, `ignore non-numeric values, which are kept unchanged`)
+	scaleCmd.Flags().StringSliceP("na-values", "", []string{"", "NA", "N/A"}, `NA values, which are kept unchanged, case ignored`)
+	scaleCmd.Flags().StringP("na", "", "NA", `content for undefined results`)
+	scaleCmd.Flags().IntP("decimal-width", "n", 4, "limit floats to N decimal points")
+}
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestScaleValues(t *testing.T) {
+	values := []float64{85, 62, 73, 95}
+	cases := []struct {
+		method string
+		expect []float64
+	}{
+		{"zscore", []float64{0.435899, -1.168210, -0.401027, 1.133338}},
+		{"minmax", []float64{23.0 / 33, 0, 11.0 / 33, 1}},
+		{"robust", []float64{6 / 17.25, -17 / 17.25, -6 / 17.25, 16 / 17.25}},
+		{"center", []float64{6.25, -16.75, -5.75, 16.25}},
+		{"rank", []float64{2.0 / 3, 0, 1.0 / 3, 1}},
+	}
+	for _, c := range cases {
+		got := scaleValues(c.method, values)
+		for i := range got {
+			if math.Abs(got[i]-c.expect[i]) > 1e-5 {
+				t.Errorf("scaleValues(%s): want %v, got %v", c.method, c.expect, got)
+				break
+			}
+		}
+	}
+
+	// ties of ranks
+	got := scaleValues("rank", []float64{91, 77, 58, 77})
+	if want := []float64{1, 0.5, 0, 0.5}; !floatSlicesEqual(got, want) {
+		t.Errorf("scaleValues(rank) with ties: want %v, got %v", want, got)
+	}
+
+	// undefined results
+	for _, method := range []string{"zscore", "minmax", "robust", "rank"} {
+		for _, values := range [][]float64{{1}, {2, 2, 2}} {
+			if method == "rank" && len(values) > 1 {
+				continue
+			}
+			for _, v := range scaleValues(method, values) {
+				if !math.IsNaN(v) {
+					t.Errorf("scaleValues(%s, %v): want NaN, got %v", method, values, v)
+				}
+			}
+		}
+	}
+}
+
+func floatSlicesEqual(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !floatsClose(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestScaleValue(t *testing.T) {
+	cases := []struct {
+		method string
+		v      float64
+		expect float64
+	}{
+		{"log", math.E, 1},
+		{"log2", 8, 3},
+		{"log10", 1000, 3},
+		{"log1p", 0, 0},
+		{"sqrt", 16, 4},
+		{"log", 0, math.NaN()},
+		{"log2", -1, math.NaN()},
+		{"log1p", -1, math.NaN()},
+		{"sqrt", -1, math.NaN()},
+	}
+	for _, c := range cases {
+		got := scaleValue(c.method, c.v)
+		if math.IsNaN(c.expect) {
+			if !math.IsNaN(got) {
+				t.Errorf("scaleValue(%s, %v): want NaN, got %v", c.method, c.v, got)
+			}
+		} else if !floatsClose(got, c.expect) {
+			t.Errorf("scaleValue(%s, %v): want %v, got %v", c.method, c.v, c.expect, got)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	file := testFile(t, "scores.csv", `name,class,score,age
+Alice,A,85,20
+Bob,A,62,22
+Cathy,A,NA,21
+David,B,91,20
+Eve,B,77,24
+Frank,B,58,-1
+Grace,A,73,23
+Henry,B,,22
+Ivy,A,95,21
+Jack,B,77,22
+`)
+
+	cases := []struct {
+		args   []string
+		expect string
+	}{
+		{
+			[]string{"-f", "score", "-m", "minmax", "-n", "2"},
+			`name,class,score,age
+Alice,A,0.73,20
+Bob,A,0.11,22
+Cathy,A,NA,21
+David,B,0.89,20
+Eve,B,0.51,24
+Frank,B,0.00,-1
+Grace,A,0.41,23
+Henry,B,,22
+Ivy,A,1.00,21
+Jack,B,0.51,22
+`,
+		},
+		{
+			[]string{"-f", "score", "-m", "rank", "-g", "class", "-n", "2"},
+			`name,class,score,age
+Alice,A,0.67,20
+Bob,A,0.00,22
+Cathy,A,NA,21
+David,B,1.00,20
+Eve,B,0.50,24
+Frank,B,0.00,-1
+Grace,A,0.33,23
+Henry,B,,22
+Ivy,A,1.00,21
+Jack,B,0.50,22
+`,
+		},
+		{
+			[]string{"-f", "score,age", "-m", "center", "-g", "class", "-n", "1"},
+			`name,class,score,age
+Alice,A,6.2,-1.4
+Bob,A,-16.8,0.6
+Cathy,A,NA,-0.4
+David,B,15.2,2.6
+Eve,B,1.2,6.6
+Frank,B,-17.8,-18.4
+Grace,A,-5.8,1.6
+Henry,B,,4.6
+Ivy,A,16.2,-0.4
+Jack,B,1.2,4.6
+`,
+		},
+		// undefined results, and other NA values
+		{
+			[]string{"-f", "age", "-m", "log10", "--na", "-", "--na-values", "22"},
+			`name,class,score,age
+Alice,A,85,1.3010
+Bob,A,62,22
+Cathy,A,NA,1.3222
+David,B,91,1.3010
+Eve,B,77,1.3802
+Frank,B,58,-
+Grace,A,73,1.3617
+Henry,B,,22
+Ivy,A,95,1.3222
+Jack,B,77,22
+`,
+		},
+		// fuzzy fields
+		{
+			[]string{"-F", "-f", "a*", "-m", "minmax", "-n", "2"},
+			`name,class,score,age
+Alice,A,85,0.84
+Bob,A,62,0.92
+Cathy,A,NA,0.88
+David,B,91,0.84
+Eve,B,77,1.00
+Frank,B,58,0.00
+Grace,A,73,0.96
+Henry,B,,0.92
+Ivy,A,95,0.88
+Jack,B,77,0.92
+`,
+		},
+	}
+	for _, c := range cases {
+		got := runCsvtk(t, append([]string{"scale", file}, c.args...)...)
+		if got != c.expect {
+			t.Errorf("csvtk scale %s:\nwant %q\ngot  %q", strings.Join(c.args, " "), c.expect, got)
+		}
+	}
+
+	// non-numeric values
+	file = testFile(t, "x.csv", "x\n1\nabc\n")
+	if _, err := execCsvtkInSubprocess(t, "scale", file, "-f", "x"); err == nil {
+		t.Errorf("csvtk scale with non-numeric values: want error, got nil")
+	}
+	got := runCsvtk(t, "scale", file, "-f", "x", "-m", "minmax", "-i")
+	if expect := "x\nNA\nabc\n"; got != expect {
+		t.Errorf("csvtk scale -i:\nwant %q\ngot  %q", expect, got)
+	}
+}
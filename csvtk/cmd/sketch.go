@@ -0,0 +1,366 @@
+// Copyright © 2016-2023 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// hashString64 returns the 64-bit FNV-1a hash of a string.
+func hashString64(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// BloomFilter is a Bloom filter for checking if a key has been seen,
+// with a bounded memory and a false positive rate.
+type BloomFilter struct {
+	bits []uint64
+	m    uint64 // number of bits
+	k    uint64 // number of hash functions
+}
+
+// NewBloomFilter creates a Bloom filter for n keys with a false positive rate of p.
+func NewBloomFilter(n uint64, p float64) (*BloomFilter, error) {
+	if n == 0 {
+		return nil, fmt.Errorf("the expected number of keys should be greater than 0")
+	}
+	if p <= 0 || p >= 1 {
+		return nil, fmt.Errorf("the false positive rate should be in range of (0, 1)")
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &BloomFilter{bits: make([]uint64, (m+63)/64), m: m, k: k}, nil
+}
+
+// Add adds a key and returns true if the key might have been added before.
+func (b *BloomFilter) Add(key string) bool {
+	// FNV-1a hashes of similar keys share many bits, which are mixed
+	// before being split into two hash values for double hashing.
+	h := mixHash64(hashString64(key))
+	h1, h2 := h&0xffffffff, h>>32|1
+	var i, j uint64
+	existed := true
+	for i = 0; i < b.k; i++ {
+		j = (h1 + i*h2) % b.m
+		if b.bits[j>>6]&(1<<(j&63)) == 0 {
+			existed = false
+			b.bits[j>>6] |= 1 << (j & 63)
+		}
+	}
+	return existed
+}
+
+// mixHash64 is the finalizer of MurmurHash3, which makes all bits of a hash
+// value well distributed.
+func mixHash64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// ---------------------------------------------------------------------------
+
+const hllPrecision = 14                      // 2^14 registers, standard error: 1.04/sqrt(2^14) = 0.81%
+const hllSparseMax = 1 << (hllPrecision - 4) // maximum number of hashes stored exactly
+
+// HyperLogLog estimates the number of distinct keys.
+// Hashes of keys are stored exactly until there are too many of them,
+// so small cardinalities are exact and cost little memory.
+type HyperLogLog struct {
+	sparse    map[uint64]struct{}
+	registers []uint8
+}
+
+// NewHyperLogLog creates a HyperLogLog.
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{sparse: make(map[uint64]struct{}, 64)}
+}
+
+// Add adds a key.
+func (h *HyperLogLog) Add(key string) {
+	h.addHash(mixHash64(hashString64(key)))
+}
+
+func (h *HyperLogLog) addHash(x uint64) {
+	if h.registers == nil {
+		h.sparse[x] = struct{}{}
+		if len(h.sparse) > hllSparseMax {
+			h.registers = make([]uint8, 1<<hllPrecision)
+			for x := range h.sparse {
+				h.addHash(x)
+			}
+			h.sparse = nil
+		}
+		return
+	}
+	i := x >> (64 - hllPrecision)
+	rho := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rho > h.registers[i] {
+		h.registers[i] = rho
+	}
+}
+
+// Count returns the estimated number of distinct keys.
+func (h *HyperLogLog) Count() uint64 {
+	if h.registers == nil {
+		return uint64(len(h.sparse))
+	}
+	m := float64(len(h.registers))
+	var sum float64
+	var zeros int
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	e := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if e <= 2.5*m && zeros > 0 { // linear counting for small cardinalities
+		e = m * math.Log(m/float64(zeros))
+	}
+	return uint64(math.Round(e))
+}
+
+// ---------------------------------------------------------------------------
+
+const cmsWidth = 2048 // error of estimated counts: e/2048 = 0.13% of total count
+const cmsDepth = 4    // the error holds with a probability of 1-exp(-4) = 98%
+
+// HeavyHitters finds the most frequent keys with a Count-Min sketch,
+// only the top K candidate keys are stored.
+type HeavyHitters struct {
+	k      int
+	counts []uint32 // cmsDepth x cmsWidth
+	top    map[string]uint64
+	minKey string
+	minCnt uint64
+}
+
+// NewHeavyHitters creates a HeavyHitters tracking k keys.
+func NewHeavyHitters(k int) *HeavyHitters {
+	return &HeavyHitters{
+		k:      k,
+		counts: make([]uint32, cmsDepth*cmsWidth),
+		top:    make(map[string]uint64, k),
+	}
+}
+
+// Add adds a key.
+func (h *HeavyHitters) Add(key string) {
+	x := mixHash64(hashString64(key))
+	h1, h2 := x&0xffffffff, x>>32|1
+	var j uint64
+	var c, est uint64
+	est = math.MaxUint64
+	for i := uint64(0); i < cmsDepth; i++ {
+		j = i*cmsWidth + (h1+i*h2)%cmsWidth
+		if h.counts[j] < math.MaxUint32 {
+			h.counts[j]++
+		}
+		if c = uint64(h.counts[j]); c < est {
+			est = c
+		}
+	}
+
+	if _, ok := h.top[key]; ok {
+		h.top[key] = est
+		if key == h.minKey {
+			h.updateMin()
+		}
+		return
+	}
+	if len(h.top) < h.k {
+		h.top[key] = est
+		if len(h.top) == 1 || est < h.minCnt {
+			h.minKey, h.minCnt = key, est
+		}
+		return
+	}
+	if est > h.minCnt {
+		delete(h.top, h.minKey)
+		h.top[key] = est
+		h.updateMin()
+	}
+}
+
+func (h *HeavyHitters) updateMin() {
+	first := true
+	for key, c := range h.top {
+		if first || c < h.minCnt || (c == h.minCnt && key < h.minKey) {
+			h.minKey, h.minCnt = key, c
+			first = false
+		}
+	}
+}
+
+// KeyCount is a key and its (estimated) count.
+type KeyCount struct {
+	Key   string
+	Count uint64
+}
+
+// Top returns the candidate keys sorted by estimated counts in descending order.
+func (h *HeavyHitters) Top() []KeyCount {
+	list := make([]KeyCount, 0, len(h.top))
+	for key, c := range h.top {
+		list = append(list, KeyCount{Key: key, Count: c})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count == list[j].Count {
+			return list[i].Key < list[j].Key
+		}
+		return list[i].Count > list[j].Count
+	})
+	return list
+}
+
+// ---------------------------------------------------------------------------
+
+const tdigestCompression = 200
+
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// TDigest estimates quantiles with a merging t-digest.
+// Quantiles are exact when the number of values is small.
+type TDigest struct {
+	centroids []centroid
+	buffer    []centroid
+	total     float64
+	min, max  float64
+}
+
+// NewTDigest creates a TDigest.
+func NewTDigest() *TDigest {
+	return &TDigest{min: math.Inf(1), max: math.Inf(-1)}
+}
+
+// Add adds a value.
+func (t *TDigest) Add(v float64) {
+	t.buffer = append(t.buffer, centroid{v, 1})
+	t.total++
+	if v < t.min {
+		t.min = v
+	}
+	if v > t.max {
+		t.max = v
+	}
+	if len(t.buffer) >= 5*tdigestCompression {
+		t.compress()
+	}
+}
+
+// Count returns the number of values.
+func (t *TDigest) Count() float64 { return t.total }
+
+// scale function k1: k(q) = compression/(2*pi) * asin(2q-1)
+func tdigestK(q float64) float64 {
+	return tdigestCompression / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+func tdigestKInv(k float64) float64 {
+	if k >= tdigestCompression/4 {
+		return 1
+	}
+	return (math.Sin(k*2*math.Pi/tdigestCompression) + 1) / 2
+}
+
+func (t *TDigest) compress() {
+	if len(t.buffer) == 0 {
+		return
+	}
+	all := append(t.centroids, t.buffer...)
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+
+	merged := make([]centroid, 0, len(t.centroids)+1)
+	cur := all[0]
+	var wSoFar float64
+	qLimit := tdigestKInv(tdigestK(0) + 1)
+	for _, c := range all[1:] {
+		if (wSoFar+cur.weight+c.weight)/t.total <= qLimit {
+			cur.weight += c.weight
+			cur.mean += (c.mean - cur.mean) * c.weight / cur.weight
+			continue
+		}
+		wSoFar += cur.weight
+		merged = append(merged, cur)
+		qLimit = tdigestKInv(tdigestK(wSoFar/t.total) + 1)
+		cur = c
+	}
+	merged = append(merged, cur)
+
+	t.centroids = merged
+	t.buffer = t.buffer[:0]
+}
+
+// Quantile returns the estimated quantile (0 <= q <= 1).
+// Values are linearly interpolated between centers of centroids,
+// which is identical to R's quantile (type=7) when no values are merged.
+func (t *TDigest) Quantile(q float64) float64 {
+	if t.total == 0 || q < 0 || q > 1 {
+		return math.NaN()
+	}
+	t.compress()
+	cs := t.centroids
+	if len(cs) == 1 {
+		if cs[0].weight == 1 {
+			return cs[0].mean
+		}
+	}
+
+	target := q * (t.total - 1)
+
+	var wSoFar, pos, prePos, preMean float64
+	prePos, preMean = 0, t.min
+	for _, c := range cs {
+		pos = wSoFar + (c.weight-1)/2
+		if target <= pos {
+			if pos == prePos {
+				return c.mean
+			}
+			return preMean + (target-prePos)/(pos-prePos)*(c.mean-preMean)
+		}
+		wSoFar += c.weight
+		prePos, preMean = pos, c.mean
+	}
+	pos = t.total - 1
+	if pos == prePos {
+		return t.max
+	}
+	return preMean + (target-prePos)/(pos-prePos)*(t.max-preMean)
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+	cases := []struct {
+		n   uint64
+		p   float64
+		err bool
+	}{
+		{0, 0.01, true},
+		{100, 0, true},
+		{100, 1, true},
+		{10000, 0.01, false},
+		{10000, 0.001, false},
+	}
+
+	for _, c := range cases {
+		bloom, err := NewBloomFilter(c.n, c.p)
+		if c.err {
+			if err == nil {
+				t.Errorf("NewBloomFilter(%d, %f): error expected", c.n, c.p)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewBloomFilter(%d, %f): %s", c.n, c.p, err)
+			continue
+		}
+
+		n := int(c.n)
+		// keys differing in a few bits
+		for i := 0; i < n; i++ {
+			bloom.Add("key" + strconv.Itoa(i))
+		}
+		// no false negatives
+		for i := 0; i < n; i++ {
+			if !bloom.Add("key" + strconv.Itoa(i)) {
+				t.Errorf("NewBloomFilter(%d, %f): false negative: key%d", c.n, c.p, i)
+				break
+			}
+		}
+		// false positive rate, bits are restored after checking each unseen key
+		saved := append([]uint64{}, bloom.bits...)
+		var fp int
+		for i := n; i < 2*n; i++ {
+			if bloom.Add("key" + strconv.Itoa(i)) {
+				fp++
+			}
+			copy(bloom.bits, saved)
+		}
+		if rate := float64(fp) / float64(n); rate > 2*c.p {
+			t.Errorf("NewBloomFilter(%d, %f): false positive rate too high: %f", c.n, c.p, rate)
+		}
+	}
+}
+
+func TestHyperLogLog(t *testing.T) {
+	cases := []struct {
+		n       int
+		maxDiff float64 // maximum relative error
+	}{
+		{0, 0},
+		{1, 0},
+		{1000, 0}, // stored exactly
+		{100000, 0.03},
+		{1000000, 0.03},
+	}
+
+	for _, c := range cases {
+		h := NewHyperLogLog()
+		for i := 0; i < c.n; i++ {
+			h.Add(strconv.Itoa(i))
+			h.Add(strconv.Itoa(i)) // duplicated keys
+		}
+		got := float64(h.Count())
+		diff := got - float64(c.n)
+		if diff < 0 {
+			diff = -diff
+		}
+		if c.n == 0 && got != 0 || c.n > 0 && diff/float64(c.n) > c.maxDiff {
+			t.Errorf("HyperLogLog: want %d (error <= %.2f), got %.0f", c.n, c.maxDiff, got)
+		}
+	}
+}
+
+func TestHeavyHitters(t *testing.T) {
+	h := NewHeavyHitters(3)
+	// key i appears i times for i = 1...100, followed by many unique keys
+	for i := 1; i <= 100; i++ {
+		for j := 0; j < i; j++ {
+			h.Add("k" + strconv.Itoa(i))
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		h.Add("u" + strconv.Itoa(i))
+	}
+
+	top := h.Top()
+	if len(top) != 3 {
+		t.Fatalf("HeavyHitters: want 3 keys, got %d", len(top))
+	}
+	for i, key := range []string{"k100", "k99", "k98"} {
+		if top[i].Key != key {
+			t.Errorf("HeavyHitters: want %s at %d, got %s", key, i, top[i].Key)
+		}
+		// overestimated by at most e/2048 of the total count with a high probability
+		if c, _ := strconv.Atoi(key[1:]); top[i].Count < uint64(c) || top[i].Count > uint64(c)+10 {
+			t.Errorf("HeavyHitters: count of %s: want ~%d, got %d", key, c, top[i].Count)
+		}
+	}
+}
+
+func TestTDigest(t *testing.T) {
+	// small data: identical to R's quantile (type=7)
+	td := NewTDigest()
+	for _, v := range []float64{3, 1, 4, 1, 5, 9, 2, 6} {
+		td.Add(v)
+	}
+	for _, c := range []struct {
+		q, expect float64
+	}{
+		{0, 1},
+		{0.25, 1.75},
+		{0.5, 3.5},
+		{0.75, 5.25},
+		{1, 9},
+	} {
+		if got := td.Quantile(c.q); got != c.expect {
+			t.Errorf("TDigest (small data): quantile %v: want %v, got %v", c.q, c.expect, got)
+		}
+	}
+
+	// large data: 0, 1, ..., n-1 in a shuffled order
+	n := 100000
+	td = NewTDigest()
+	for i := 0; i < n; i++ {
+		td.Add(float64((i * 7919) % n))
+	}
+	if td.Count() != float64(n) {
+		t.Errorf("TDigest: count: want %d, got %f", n, td.Count())
+	}
+	for _, q := range []float64{0, 0.001, 0.01, 0.25, 0.5, 0.75, 0.99, 0.999, 1} {
+		expect := q * float64(n-1)
+		got := td.Quantile(q)
+		if diff := got - expect; diff < -0.01*float64(n) || diff > 0.01*float64(n) {
+			t.Errorf("TDigest (large data): quantile %v: want ~%.0f, got %.1f", q, expect, got)
+		}
+	}
+
+	// empty or invalid
+	td = NewTDigest()
+	if got := td.Quantile(0.5); got == got {
+		t.Errorf("TDigest (empty): want NaN, got %v", got)
+	}
+}
@@ -21,7 +21,6 @@
 package cmd
 
 import (
-	"encoding/csv"
 	"fmt"
 	"runtime"
 	"strconv"
@@ -76,109 +75,18 @@ Combinations:
 		keys := getFlagStringSlice(cmd, "keys")
 		ignoreCase := getFlagBool(cmd, "ignore-case")
 
-		levelsMap := make(map[string]map[string]int)
-		var items []string
-		for _, level := range levels {
-			items = strings.Split(level, ":")
-			if len(items) != 2 {
-				checkError(fmt.Errorf("invalid level information format: %s", level))
-			}
-
-			m := make(map[string]int)
-			reader, err := breader.NewDefaultBufferedReader(items[1])
-			checkError(errors.Wrap(err, "read level file"))
-			var i int
-			for chunk := range reader.Ch {
-				checkError(chunk.Err)
-				for _, data := range chunk.Data {
-					line := data.(string)
-					if line == "" {
-						continue
-					}
-					i++
-					if ignoreCase {
-						m[strings.ToLower(line)] = i
-					} else {
-						m[line] = i
-					}
-				}
-			}
-			if _, ok := levelsMap[items[0]]; ok {
-				if config.Verbose {
-					log.Warningf("overide user-defined level for field %s", items[0])
-				}
-			}
-			levelsMap[items[0]] = m
-		}
-
-		sortTypes := []sortType{}
-		fieldsStrs := []string{}
-		var i int
-		var _key, _type string
-		for _, key := range keys {
-			i = strings.LastIndexByte(key, ':')
-			if i < 0 || i == len(key)-1 {
-				_key = key
-				fieldsStrs = append(fieldsStrs, _key)
-				sortTypes = append(sortTypes, sortType{FieldStr: _key, Number: false, Reverse: false})
-			} else if i == 0 {
-				checkError(fmt.Errorf(`invalid key: "%s"`, key))
-			} else {
-				_key = key[:i]
-				fieldsStrs = append(fieldsStrs, _key)
-				_type = key[i+1:]
-				switch _type {
-				case "N":
-					sortTypes = append(sortTypes, sortType{FieldStr: _key, Natural: true, Reverse: false})
-				case "Nr", "rN":
-					sortTypes = append(sortTypes, sortType{FieldStr: _key, Natural: true, Reverse: true})
-				case "n":
-					sortTypes = append(sortTypes, sortType{FieldStr: _key, Number: true, Reverse: false})
-				case "r":
-					sortTypes = append(sortTypes, sortType{FieldStr: _key, Number: false, Reverse: true})
-				case "nr", "rn":
-					sortTypes = append(sortTypes, sortType{FieldStr: _key, Number: true, Reverse: true})
-				case "d":
-					sortTypes = append(sortTypes, sortType{FieldStr: _key, Date: true, Reverse: false})
-				case "dr":
-					sortTypes = append(sortTypes, sortType{FieldStr: _key, Date: true, Reverse: true})
-				case "u":
-					if _, ok := levelsMap[_key]; !ok {
-						checkError(fmt.Errorf("level file not provided for field: %s", _key))
-					}
-					sortTypes = append(sortTypes, sortType{FieldStr: _key, Number: false, Reverse: false, UserDefined: true, Levels: levelsMap[_key]})
-				case "ur", "ru":
-					if _, ok := levelsMap[_key]; !ok {
-						checkError(fmt.Errorf("level file not provided for field: %s", _key))
-					}
-					sortTypes = append(sortTypes, sortType{FieldStr: _key, Number: false, Reverse: true, UserDefined: true, Levels: levelsMap[_key]})
-				default:
-					// checkError(fmt.Errorf("invalid sort type: %s", _type))
-					_key = key
-					fieldsStrs[len(fieldsStrs)-1] = _key
-					sortTypes = append(sortTypes, sortType{FieldStr: _key, Number: false, Reverse: false})
-				}
-			}
-		}
+		levelsMap := parseSortLevels(levels, ignoreCase, config.Verbose)
+		fieldsStrs, sortTypes := parseSortKeys(keys, levelsMap)
 
 		fieldsStr := strings.Join(fieldsStrs, ",")
 
 		fuzzyFields := false
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
-		writer := csv.NewWriter(outfh)
-		if config.OutTabs || config.Tabs {
-			if config.OutDelimiter == ',' {
-				writer.Comma = '\t'
-			} else {
-				writer.Comma = config.OutDelimiter
-			}
-		} else {
-			writer.Comma = config.OutDelimiter
-		}
+		writer := newRecordWriter(config, outfh)
 		defer func() {
 			writer.Flush()
 			checkError(writer.Error())
@@ -218,7 +126,7 @@ Combinations:
 		var list []stringutil.MultiKeyStringSlice // data
 
 		sortTypes2 := make([]stringutil.SortType, 0, len(sortTypes))
-		var field int
+		var i, field int
 		ncols := len(data[0])
 		_fields := make([]int, 0, ncols)
 		var start, end int
@@ -354,6 +262,99 @@ type sortType struct {
 	Levels      map[string]int
 }
 
+// parseSortLevels reads user-defined level files in the format of <field>:<level-file>.
+func parseSortLevels(levels []string, ignoreCase bool, verbose bool) map[string]map[string]int {
+	levelsMap := make(map[string]map[string]int)
+	var items []string
+	for _, level := range levels {
+		items = strings.Split(level, ":")
+		if len(items) != 2 {
+			checkError(fmt.Errorf("invalid level information format: %s", level))
+		}
+
+		m := make(map[string]int)
+		reader, err := breader.NewDefaultBufferedReader(items[1])
+		checkError(errors.Wrap(err, "read level file"))
+		var i int
+		for chunk := range reader.Ch {
+			checkError(chunk.Err)
+			for _, data := range chunk.Data {
+				line := data.(string)
+				if line == "" {
+					continue
+				}
+				i++
+				if ignoreCase {
+					m[strings.ToLower(line)] = i
+				} else {
+					m[line] = i
+				}
+			}
+		}
+		if _, ok := levelsMap[items[0]]; ok {
+			if verbose {
+				log.Warningf("overide user-defined level for field %s", items[0])
+			}
+		}
+		levelsMap[items[0]] = m
+	}
+	return levelsMap
+}
+
+// parseSortKeys parses sort keys like "1:nr", and returns fields and sort types.
+func parseSortKeys(keys []string, levelsMap map[string]map[string]int) ([]string, []sortType) {
+	sortTypes := []sortType{}
+	fieldsStrs := []string{}
+	var i int
+	var _key, _type string
+	for _, key := range keys {
+		i = strings.LastIndexByte(key, ':')
+		if i < 0 || i == len(key)-1 {
+			_key = key
+			fieldsStrs = append(fieldsStrs, _key)
+			sortTypes = append(sortTypes, sortType{FieldStr: _key, Number: false, Reverse: false})
+		} else if i == 0 {
+			checkError(fmt.Errorf(`invalid key: "%s"`, key))
+		} else {
+			_key = key[:i]
+			fieldsStrs = append(fieldsStrs, _key)
+			_type = key[i+1:]
+			switch _type {
+			case "N":
+				sortTypes = append(sortTypes, sortType{FieldStr: _key, Natural: true, Reverse: false})
+			case "Nr", "rN":
+				sortTypes = append(sortTypes, sortType{FieldStr: _key, Natural: true, Reverse: true})
+			case "n":
+				sortTypes = append(sortTypes, sortType{FieldStr: _key, Number: true, Reverse: false})
+			case "r":
+				sortTypes = append(sortTypes, sortType{FieldStr: _key, Number: false, Reverse: true})
+			case "nr", "rn":
+				sortTypes = append(sortTypes, sortType{FieldStr: _key, Number: true, Reverse: true})
+			case "d":
+				sortTypes = append(sortTypes, sortType{FieldStr: _key, Date: true, Reverse: false})
+			case "dr":
+				sortTypes = append(sortTypes, sortType{FieldStr: _key, Date: true, Reverse: true})
+			case "u":
+				if _, ok := levelsMap[_key]; !ok {
+					checkError(fmt.Errorf("level file not provided for field: %s", _key))
+				}
+				sortTypes = append(sortTypes, sortType{FieldStr: _key, Number: false, Reverse: false, UserDefined: true, Levels: levelsMap[_key]})
+			case "ur", "ru":
+				if _, ok := levelsMap[_key]; !ok {
+					checkError(fmt.Errorf("level file not provided for field: %s", _key))
+				}
+				sortTypes = append(sortTypes, sortType{FieldStr: _key, Number: false, Reverse: true, UserDefined: true, Levels: levelsMap[_key]})
+			default:
+				// checkError(fmt.Errorf("invalid sort type: %s", _type))
+				_key = key
+				fieldsStrs[len(fieldsStrs)-1] = _key
+				sortTypes = append(sortTypes, sortType{FieldStr: _key, Number: false, Reverse: false})
+			}
+		}
+	}
+	return fieldsStrs, sortTypes
+}
+
 func init() {
 	RootCmd.AddCommand(sortCmd)
 	sortCmd.Flags().StringSliceP("keys", "k", []string{"1-"}, `keys (multiple values supported). sort type supported, "N" for natural order, "n" for number, "d" for date/time, "u" for user-defined order and "r" for reverse. e.g., "-k 1", "-k 2-", "-k 3-5:nr", "-k A:r", "-k 1:nr -k 2"`)
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseSortKeys(t *testing.T) {
+	levels := map[string]map[string]int{"name": {"b": 1, "a": 2}}
+
+	cases := []struct {
+		keys   []string
+		fields []string
+		types  []sortType
+	}{
+		{
+			keys:   []string{"1"},
+			fields: []string{"1"},
+			types:  []sortType{{FieldStr: "1"}},
+		},
+		{
+			keys:   []string{"1:n", "2:r", "3:nr", "4:rn"},
+			fields: []string{"1", "2", "3", "4"},
+			types: []sortType{
+				{FieldStr: "1", Number: true},
+				{FieldStr: "2", Reverse: true},
+				{FieldStr: "3", Number: true, Reverse: true},
+				{FieldStr: "4", Number: true, Reverse: true},
+			},
+		},
+		{
+			keys:   []string{"a:N", "b:Nr", "c:d", "d:dr"},
+			fields: []string{"a", "b", "c", "d"},
+			types: []sortType{
+				{FieldStr: "a", Natural: true},
+				{FieldStr: "b", Natural: true, Reverse: true},
+				{FieldStr: "c", Date: true},
+				{FieldStr: "d", Date: true, Reverse: true},
+			},
+		},
+		{
+			keys:   []string{"name:u", "name:ur"},
+			fields: []string{"name", "name"},
+			types: []sortType{
+				{FieldStr: "name", UserDefined: true, Levels: levels["name"]},
+				{FieldStr: "name", UserDefined: true, Reverse: true, Levels: levels["name"]},
+			},
+		},
+		// the last colon separates the sort type
+		{
+			keys:   []string{"a:b:n"},
+			fields: []string{"a:b"},
+			types:  []sortType{{FieldStr: "a:b", Number: true}},
+		},
+		// colons in column names without sort types
+		{
+			keys:   []string{"a:b", "c:"},
+			fields: []string{"a:b", "c:"},
+			types:  []sortType{{FieldStr: "a:b"}, {FieldStr: "c:"}},
+		},
+		// ranges
+		{
+			keys:   []string{"3-5:nr"},
+			fields: []string{"3-5"},
+			types:  []sortType{{FieldStr: "3-5", Number: true, Reverse: true}},
+		},
+	}
+
+	for _, c := range cases {
+		fields, types := parseSortKeys(c.keys, levels)
+		if !reflect.DeepEqual(fields, c.fields) {
+			t.Errorf("parseSortKeys(%v): fields: want %v, got %v", c.keys, c.fields, fields)
+		}
+		if !reflect.DeepEqual(types, c.types) {
+			t.Errorf("parseSortKeys(%v): sort types:\nwant %+v\ngot  %+v", c.keys, c.types, types)
+		}
+	}
+}
+
+func TestParseSortLevels(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "levels.txt")
+	if err := os.WriteFile(file, []byte("High\n\nMedium\nLow\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		ignoreCase bool
+		expect     map[string]map[string]int
+	}{
+		{false, map[string]map[string]int{"level": {"High": 1, "Medium": 2, "Low": 3}}},
+		{true, map[string]map[string]int{"level": {"high": 1, "medium": 2, "low": 3}}},
+	}
+	for _, c := range cases {
+		got := parseSortLevels([]string{"level:" + file}, c.ignoreCase, false)
+		if !reflect.DeepEqual(got, c.expect) {
+			t.Errorf("parseSortLevels (ignore case: %v): want %v, got %v", c.ignoreCase, c.expect, got)
+		}
+	}
+}
@@ -54,7 +54,7 @@ var space2tabCmd = &cobra.Command{
 			checkError(fmt.Errorf("invalid value of buffer size. supported unit: K, M, G"))
 		}
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
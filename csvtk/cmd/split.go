@@ -21,11 +21,12 @@
 package cmd
 
 import (
-	"encoding/csv"
 	"fmt"
+	"hash/fnv"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -46,6 +47,19 @@ Notes:
   1. flag -o/--out-file can specify out directory for splitted files.
   2. flag -s/--prefix-as-subdir can create subdirectories with prefixes of
      keys of length X, to avoid writing too many files in the output directory.
+  3. For key fields with high cardinality, use -B/--buckets K to route records
+     into K files (named with 1-based bucket indexes) by a stable hash (FNV-1a)
+     of key fields, instead of one file per distinct key.
+  4. Instead of key fields, records can also be split into chunks:
+       -n/--by-rows N    every N records per file.
+       -S/--by-size S    approximate output size (uncompressed) per file, e.g., 100M.
+       -k/--by-parts K   K parts with (nearly) equal numbers of records. Records are
+                         assigned in a round-robin way with -r/--round-robin,
+                         otherwise all records are kept in memory to split
+                         them contiguously.
+     Chunks are named with 1-based indexes, e.g., prefix-001.csv, and the header
+     row is repeated in each chunk.
+  5. Only csv and tsv are supported by the global flag --out-format.
 
 `,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -56,6 +70,14 @@ Notes:
 		}
 		runtime.GOMAXPROCS(config.NumCPUs)
 
+		// records of a file may be appended in multiple batches,
+		// which is not supported by formats with a header or a footer.
+		switch config.OutFormat {
+		case "csv", "tsv":
+		default:
+			checkError(fmt.Errorf("output format %s is not supported by split, please use csv or tsv", config.OutFormat))
+		}
+
 		fieldStr := getFlagString(cmd, "fields")
 		if fieldStr == "" {
 			checkError(fmt.Errorf("flag -f (--fields) needed"))
@@ -66,10 +88,60 @@ Notes:
 		bufRowsSize := getFlagNonNegativeInt(cmd, "buf-rows")
 		bufGroupsSize := getFlagNonNegativeInt(cmd, "buf-groups")
 		gzipped := getFlagBool(cmd, "out-gzip")
+		compression := strings.TrimPrefix(strings.ToLower(getFlagString(cmd, "out-compress")), ".")
+		if gzipped {
+			if compression != "" && compression != "gz" {
+				checkError(fmt.Errorf("flag -G/--out-gzip and --out-compress are exclusive"))
+			}
+			compression = "gz"
+		}
+		if compression != "" {
+			var ok bool
+			for _, s := range CompressionSuffixes {
+				if "."+compression == s {
+					ok = true
+					break
+				}
+			}
+			if !ok {
+				checkError(fmt.Errorf("unsupported compression format: %s. available values: gz, xz, zst, bz2, lz4", compression))
+			}
+		}
 		outPrefix := getFlagString(cmd, "out-prefix")
 		subdirLen := getFlagNonNegativeInt(cmd, "prefix-as-subdir")
 		force := getFlagBool(cmd, "force")
 
+		byRows := getFlagNonNegativeInt(cmd, "by-rows")
+		bySize, err := ParseByteSize(getFlagString(cmd, "by-size"))
+		checkError(err)
+		byParts := getFlagNonNegativeInt(cmd, "by-parts")
+		roundRobin := getFlagBool(cmd, "round-robin")
+		var nModes int
+		for _, v := range []int64{int64(byRows), bySize, int64(byParts)} {
+			if v > 0 {
+				nModes++
+			}
+		}
+		if nModes > 1 {
+			checkError(fmt.Errorf("flags -n/--by-rows, -S/--by-size and -k/--by-parts are exclusive"))
+		}
+		byChunks := nModes == 1
+		buckets := getFlagNonNegativeInt(cmd, "buckets")
+		if buckets > 0 && byChunks {
+			checkError(fmt.Errorf("flag -B/--buckets can not be used with -n/--by-rows, -S/--by-size or -k/--by-parts"))
+		}
+		bucketWidth := len(strconv.Itoa(buckets))
+		if bucketWidth < 3 {
+			bucketWidth = 3
+		}
+		hasher := fnv.New64a()
+		if roundRobin && byParts == 0 {
+			checkError(fmt.Errorf("flag -r/--round-robin only works with -k/--by-parts"))
+		}
+		if byChunks {
+			fieldStr = "1-"
+		}
+
 		file := files[0]
 		csvReader, err := newCSVReaderByConfig(config, file)
 		checkError(err)
@@ -81,7 +153,7 @@ Notes:
 			DoNotAllowDuplicatedColumnName: true,
 		})
 
-		var outFilePrefix, outFileSuffix string
+		var outFilePrefix, outFileSuffix, compressionSuffix string
 		if isStdin(file) {
 			if config.OutTabs || config.Tabs {
 				outFilePrefix, outFileSuffix = "stdin", ".tsv"
@@ -89,12 +161,15 @@ Notes:
 				outFilePrefix, outFileSuffix = "stdin", ".csv"
 			}
 		} else {
-			outFilePrefix, outFileSuffix = filepathTrimExtension(file)
+			outFilePrefix, outFileSuffix, compressionSuffix = filepathTrimExtension2(file, nil)
+		}
+		if config.OutFormat == "tsv" {
+			outFileSuffix = ".tsv"
 		}
-		if gzipped &&
-			!strings.HasSuffix(strings.ToLower(outFileSuffix), ".gz") {
-			outFileSuffix = outFileSuffix + ".gz"
+		if compression != "" {
+			compressionSuffix = "." + compression
 		}
+		outFileSuffix += compressionSuffix
 
 		outdir := "./"
 		if config.OutFile != "-" { // outdir
@@ -120,6 +195,12 @@ Notes:
 			return filepath.Join(outdir, outFilePrefix+key+outFileSuffix)
 		}
 
+		if byChunks {
+			splitIntoChunks(config, csvReader, outfile, byRows, bySize, byParts, roundRobin)
+			readerReport(&config, csvReader, file)
+			return
+		}
+
 		var key string
 		var headerRow []string
 		// moreThanOneWrite := make(map[string]bool)
@@ -141,9 +222,21 @@ Notes:
 				}
 			}
 
-			key = strings.Join(record.Selected, "-")
-			if ignoreCase {
-				key = strings.ToLower(key)
+			if buckets > 0 {
+				hasher.Reset()
+				for _, v := range record.Selected {
+					if ignoreCase {
+						v = strings.ToLower(v)
+					}
+					hasher.Write([]byte(v))
+					hasher.Write([]byte{0})
+				}
+				key = fmt.Sprintf("%0*d", bucketWidth, hasher.Sum64()%uint64(buckets)+1)
+			} else {
+				key = strings.Join(record.Selected, "-")
+				if ignoreCase {
+					key = strings.ToLower(key)
+				}
 			}
 
 			row := make([]string, len(record.All))
@@ -217,6 +310,8 @@ Notes:
 
 		wg.Wait()
 
+		renameSplitFiles()
+
 		readerReport(&config, csvReader, file)
 	},
 }
@@ -226,15 +321,179 @@ func init() {
 	splitCmd.Flags().StringP("fields", "f", "1", `comma separated key fields, column name or index. e.g. -f 1-3 or -f id,id2 or -F -f "group*"`)
 	splitCmd.Flags().BoolP("fuzzy-fields", "F", false, `using fuzzy fields, e.g., -F -f "*name" or -F -f "id123*"`)
 	splitCmd.Flags().BoolP("ignore-case", "i", false, `ignore case`)
-	splitCmd.Flags().BoolP("out-gzip", "G", false, `force output gzipped file`)
+	splitCmd.Flags().BoolP("out-gzip", "G", false, `force output gzipped file, equal to --out-compress gz`)
+	splitCmd.Flags().StringP("out-compress", "", "", `compression format of output files, available values: gz, xz, zst, bz2, lz4. `+
+		`by default, the compression format of the input file is used. the compression level is set by "--compress-level"`)
 	splitCmd.Flags().IntP("buf-rows", "b", 100000, `buffering N rows for every group before writing to file`)
 	splitCmd.Flags().IntP("buf-groups", "g", 100, `buffering N groups before writing to file`)
 	splitCmd.Flags().StringP("out-prefix", "p", "", `output file prefix, the default value is the input file. use -p "" to disable outputting prefix`)
 	splitCmd.Flags().IntP("prefix-as-subdir", "s", 0, `create subdirectories with prefixes of keys of length X, to avoid writing too many files in the output directory`)
 	splitCmd.Flags().BoolP("force", "", false, `overwrite existing output directory (given by -o).`)
+
+	splitCmd.Flags().IntP("buckets", "B", 0, `split into K buckets by the hash of key fields, instead of one file per key`)
+	splitCmd.Flags().IntP("by-rows", "n", 0, `split into chunks of N records, instead of by key fields`)
+	splitCmd.Flags().StringP("by-size", "S", "", `split into chunks of approximate output (uncompressed) size, e.g., 100K, 50M, 1G, instead of by key fields`)
+	splitCmd.Flags().IntP("by-parts", "k", 0, `split into K parts with equal numbers of records, instead of by key fields`)
+	splitCmd.Flags().BoolP("round-robin", "r", false, `assign records to parts in a round-robin way, for -k/--by-parts`)
+}
+
+// chunkWriter writes records of a chunk file.
+type chunkWriter struct {
+	outfh  *OutWriter
+	writer RecordWriter
+}
+
+func newChunkWriter(config Config, file string, headerRow []string) *chunkWriter {
+	outfh, err := wopen(file, "")
+	checkError(err)
+	writer := newRecordWriter(config, outfh)
+	if headerRow != nil {
+		checkError(writer.Write(headerRow))
+	}
+	return &chunkWriter{outfh: outfh, writer: writer}
+}
+
+func (w *chunkWriter) Close() {
+	w.writer.Flush()
+	checkError(w.writer.Error())
+	checkError(w.outfh.Close())
+}
+
+// splitIntoChunks splits records by the number of rows, approximate size,
+// or the number of parts.
+func splitIntoChunks(config Config,
+	csvReader *CSVReader,
+	outfile func(string) string,
+	byRows int,
+	bySize int64,
+	byParts int,
+	roundRobin bool,
+) {
+	chunkName := func(i int, width int) string {
+		return fmt.Sprintf("%0*d", width, i+1)
+	}
+	width := 3
+	if byParts > 0 && len(strconv.Itoa(byParts)) > width {
+		width = len(strconv.Itoa(byParts))
+	}
+
+	// the approximate size of a record in CSV format
+	recordSize := func(record []string) int64 {
+		n := int64(len(record))
+		for _, v := range record {
+			n += int64(len(v))
+		}
+		return n
+	}
+
+	var headerRow []string
+	var headerSize int64
+	var rows [][]string // only for contiguous parts
+
+	var writers []*chunkWriter // only for round-robin parts
+	var writer *chunkWriter
+	var iChunk, nRows, n int
+	var size, s int64
+
+	checkFirstLine := true
+	for record := range csvReader.Ch {
+		if record.Err != nil {
+			checkError(record.Err)
+		}
+
+		if checkFirstLine {
+			checkFirstLine = false
+
+			if !config.NoHeaderRow || record.IsHeaderRow {
+				if !config.NoOutHeader {
+					headerRow = record.All
+					headerSize = recordSize(headerRow)
+				}
+				continue
+			}
+		}
+
+		if byParts > 0 {
+			if !roundRobin {
+				rows = append(rows, record.All)
+				continue
+			}
+
+			if writers == nil {
+				writers = make([]*chunkWriter, byParts)
+			}
+			if writers[iChunk] == nil {
+				writers[iChunk] = newChunkWriter(config, outfile(chunkName(iChunk, width)), headerRow)
+			}
+			checkError(writers[iChunk].writer.Write(record.All))
+			iChunk++
+			if iChunk == byParts {
+				iChunk = 0
+			}
+			continue
+		}
+
+		s = recordSize(record.All)
+		if writer != nil &&
+			((byRows > 0 && nRows == byRows) ||
+				(bySize > 0 && size+s > bySize && nRows > 0)) {
+			writer.Close()
+			writer = nil
+			iChunk++
+		}
+		if writer == nil {
+			writer = newChunkWriter(config, outfile(chunkName(iChunk, width)), headerRow)
+			nRows = 0
+			size = headerSize
+		}
+		checkError(writer.writer.Write(record.All))
+		nRows++
+		size += s
+	}
+
+	if writer != nil {
+		writer.Close()
+	}
+	for _, w := range writers {
+		if w != nil {
+			w.Close()
+		}
+	}
+
+	if byParts == 0 || roundRobin {
+		return
+	}
+
+	// contiguous parts
+	var end int
+	start := 0
+	for iChunk = 0; iChunk < byParts && start < len(rows); iChunk++ {
+		n = len(rows) / byParts
+		if iChunk < len(rows)%byParts {
+			n++
+		}
+		end = start + n
+
+		writer = newChunkWriter(config, outfile(chunkName(iChunk, width)), headerRow)
+		for _, row := range rows[start:end] {
+			checkError(writer.writer.Write(row))
+		}
+		writer.Close()
+
+		start = end
+	}
 }
 
-var writtenFiles sync.Map
+// splitFiles stores temporary files of output files, with output files as keys.
+// Records of a key are appended into the temporary file in batches,
+// which is renamed to the output file after all records are written.
+var splitFiles sync.Map
+
+// splitFile is an output file and its temporary file.
+type splitFile struct {
+	file    string
+	tmpFile string
+}
 
 func appendRows(config Config,
 	csvReader *CSVReader,
@@ -247,26 +506,23 @@ func appendRows(config Config,
 	var outfh *xopen.Writer
 	var err error
 
-	_, written := writtenFiles.Load(key)
+	var tmpFile string
+	v, written := splitFiles.Load(outFile)
 	if written {
-		outfh, err = xopen.WopenFile(outFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		tmpFile = v.(*splitFile).tmpFile
+		outfh, err = xopen.WopenFile(tmpFile, os.O_APPEND|os.O_WRONLY, 0644)
 	} else {
-		outfh, err = xopen.Wopen(outFile)
-		writtenFiles.Store(key, true)
+		tmpFile = tmpFileName(outFile)
+		tmpFiles.Store(tmpFile, struct{}{})
+		splitFiles.Store(outFile, &splitFile{file: outFile, tmpFile: tmpFile})
+		outfh, err = xopen.Wopen(tmpFile)
 	}
 	checkError(err)
-	defer outfh.Close()
+	defer func() {
+		checkError(outfh.Close())
+	}()
 
-	writer := csv.NewWriter(outfh)
-	if config.OutTabs || config.Tabs {
-		if config.OutDelimiter == ',' {
-			writer.Comma = '\t'
-		} else {
-			writer.Comma = config.OutDelimiter
-		}
-	} else {
-		writer.Comma = config.OutDelimiter
-	}
+	writer := newRecordWriter(config, outfh)
 	defer func() {
 		writer.Flush()
 		checkError(writer.Error())
@@ -281,3 +537,16 @@ func appendRows(config Config,
 	}
 
 }
+
+// renameSplitFiles renames temporary files to output files.
+func renameSplitFiles() {
+	splitFiles.Range(func(key, value interface{}) bool {
+		f := value.(*splitFile)
+		if err := os.Rename(f.tmpFile, f.file); err != nil {
+			checkError(fmt.Errorf("rename temporary file to %s: %s", f.file, err))
+		}
+		tmpFiles.Delete(f.tmpFile)
+		splitFiles.Delete(key)
+		return true
+	})
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitChunks(t *testing.T) {
+	file := testFile(t, "s.csv", "id,g\n1,a\n2,b\n3,a\n4,c\n5,b\n6,a\n7,c\n")
+
+	cases := []struct {
+		args   []string
+		expect map[string]string
+	}{
+		{
+			[]string{"-n", "3"},
+			map[string]string{
+				"s-001.csv": "id,g\n1,a\n2,b\n3,a\n",
+				"s-002.csv": "id,g\n4,c\n5,b\n6,a\n",
+				"s-003.csv": "id,g\n7,c\n",
+			},
+		},
+		// the header row is counted in the size of each chunk
+		{
+			[]string{"-S", "13"},
+			map[string]string{
+				"s-001.csv": "id,g\n1,a\n2,b\n",
+				"s-002.csv": "id,g\n3,a\n4,c\n",
+				"s-003.csv": "id,g\n5,b\n6,a\n",
+				"s-004.csv": "id,g\n7,c\n",
+			},
+		},
+		// a record larger than the size is written into a single chunk
+		{
+			[]string{"-S", "1"},
+			map[string]string{
+				"s-001.csv": "id,g\n1,a\n",
+				"s-002.csv": "id,g\n2,b\n",
+				"s-003.csv": "id,g\n3,a\n",
+				"s-004.csv": "id,g\n4,c\n",
+				"s-005.csv": "id,g\n5,b\n",
+				"s-006.csv": "id,g\n6,a\n",
+				"s-007.csv": "id,g\n7,c\n",
+			},
+		},
+		{
+			[]string{"-k", "3"},
+			map[string]string{
+				"s-001.csv": "id,g\n1,a\n2,b\n3,a\n",
+				"s-002.csv": "id,g\n4,c\n5,b\n",
+				"s-003.csv": "id,g\n6,a\n7,c\n",
+			},
+		},
+		{
+			[]string{"-k", "3", "-r"},
+			map[string]string{
+				"s-001.csv": "id,g\n1,a\n4,c\n7,c\n",
+				"s-002.csv": "id,g\n2,b\n5,b\n",
+				"s-003.csv": "id,g\n3,a\n6,a\n",
+			},
+		},
+		// more parts than records
+		{
+			[]string{"-k", "10", "-U"},
+			map[string]string{
+				"s-001.csv": "1,a\n",
+				"s-002.csv": "2,b\n",
+				"s-003.csv": "3,a\n",
+				"s-004.csv": "4,c\n",
+				"s-005.csv": "5,b\n",
+				"s-006.csv": "6,a\n",
+				"s-007.csv": "7,c\n",
+			},
+		},
+		{
+			[]string{"-n", "4", "--out-format", "tsv", "-G"},
+			map[string]string{
+				"s-001.tsv.gz": "id\tg\n1\ta\n2\tb\n3\ta\n4\tc\n",
+				"s-002.tsv.gz": "id\tg\n5\tb\n6\ta\n7\tc\n",
+			},
+		},
+	}
+	for _, c := range cases {
+		outdir := filepath.Join(t.TempDir(), "out")
+		execCsvtk(t, append([]string{"split", file, "-p", "s-", "-o", outdir}, c.args...)...)
+		if got := readOutFiles(t, outdir); !reflect.DeepEqual(got, c.expect) {
+			t.Errorf("csvtk split %v:\nwant %q\ngot  %q", c.args, c.expect, got)
+		}
+	}
+}
+
+func TestSplitBuckets(t *testing.T) {
+	file := testFile(t, "s.csv", "id,g\n1,a\n2,b\n3,A\n4,c\n5,b\n6,a\n7,c\n")
+
+	cases := []struct {
+		args   []string
+		expect map[string]string
+	}{
+		{
+			[]string{"-f", "g", "-B", "2"},
+			map[string]string{
+				"s-001.csv": "id,g\n1,a\n3,A\n4,c\n6,a\n7,c\n",
+				"s-002.csv": "id,g\n2,b\n5,b\n",
+			},
+		},
+		{
+			[]string{"-f", "g", "-B", "2", "-i"},
+			map[string]string{
+				"s-001.csv": "id,g\n1,a\n3,A\n4,c\n6,a\n7,c\n",
+				"s-002.csv": "id,g\n2,b\n5,b\n",
+			},
+		},
+		// records are appended to bucket files in multiple batches
+		{
+			[]string{"-f", "g", "-B", "2", "-i", "-b", "1", "-g", "1", "-j", "1"},
+			map[string]string{
+				"s-001.csv": "id,g\n1,a\n3,A\n4,c\n6,a\n7,c\n",
+				"s-002.csv": "id,g\n2,b\n5,b\n",
+			},
+		},
+		// bucket indexes are padded to the width of the number of buckets
+		{
+			[]string{"-f", "g", "-B", "1000"},
+			map[string]string{
+				"s-0085.csv": "id,g\n1,a\n6,a\n",
+				"s-0120.csv": "id,g\n2,b\n5,b\n",
+				"s-0125.csv": "id,g\n3,A\n",
+				"s-0775.csv": "id,g\n4,c\n7,c\n",
+			},
+		},
+		{
+			[]string{"-f", "g", "-B", "1000", "-i"},
+			map[string]string{
+				"s-0085.csv": "id,g\n1,a\n3,A\n6,a\n",
+				"s-0120.csv": "id,g\n2,b\n5,b\n",
+				"s-0775.csv": "id,g\n4,c\n7,c\n",
+			},
+		},
+	}
+	for _, c := range cases {
+		outdir := filepath.Join(t.TempDir(), "out")
+		execCsvtk(t, append([]string{"split", file, "-p", "s-", "-o", outdir}, c.args...)...)
+		if got := readOutFiles(t, outdir); !reflect.DeepEqual(got, c.expect) {
+			t.Errorf("csvtk split %v:\nwant %q\ngot  %q", c.args, c.expect, got)
+		}
+	}
+}
@@ -289,7 +289,10 @@ Weakness : Complicated sheet structures are not well supported, e.g.,
 			prefx, _ := filepathTrimExtension(files[0])
 			config.OutFile = fmt.Sprintf("%s.split.xlsx", prefx)
 		}
-		checkError(xlsx.SaveAs(config.OutFile))
+		outfh, err := wopen(config.OutFile, config.BackupSuffix)
+		checkError(err)
+		checkError(xlsx.Write(outfh))
+		checkError(outfh.Close())
 	},
 }
 
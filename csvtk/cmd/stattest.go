@@ -0,0 +1,547 @@
+// Copyright © 2016-2023 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// statTestCmd represents the test command
+var statTestCmd = &cobra.Command{
+	GroupID: "info",
+
+	Use:   "test",
+	Short: "statistical tests: t-test, Mann-Whitney U test, chi-squared test and Kolmogorov-Smirnov test",
+	Long: `statistical tests: t-test, Mann-Whitney U test, chi-squared test and Kolmogorov-Smirnov test
+
+Methods (-m/--method):
+  ttest         one-sample t-test:  -f x [--mu 0]
+                two-sample t-test:  -f x,y  or  -f value -g group [--mu 0]
+                                    (Welch's t-test, use --equal-var for
+                                    Student's t-test)
+                paired t-test:      -f x,y --paired [--mu 0]
+  mannwhitney   Mann-Whitney U test (Wilcoxon rank-sum test), with normal
+                approximation, tie correction and continuity correction.
+                -f x,y  or  -f value -g group
+  chisq         chi-squared test of independence on two categorical columns,
+                without continuity correction.
+                -f a,b
+  ks            two-sample Kolmogorov-Smirnov test, with the asymptotic
+                distribution.
+                -f x,y  or  -f value -g group
+
+Samples:
+  1. Two samples can be given as two columns, or values of a column
+     grouped by a group field (-g/--group). In the latter case, the two
+     groups can be chosen with --levels, otherwise there should be exactly
+     two groups, which are used in the order of first appearance.
+  2. Non-numeric values like "NA" are not allowed unless -i/--ignore-non-numbers
+     is given. For the paired t-test, pairs with non-numeric values are removed.
+  3. For t-tests, --mu is the hypothesized mean of the one-sample t-test,
+     or the hypothesized difference in means (sample1 - sample2) of
+     two-sample and paired t-tests.
+
+Output columns:
+  method, sample1, sample2, n1, n2, statistic, df, pvalue
+
+  statistic: t for t-test, W (U of sample1) for Mann-Whitney U test,
+             X-squared for chi-squared test, and D for KS test.
+  For the chi-squared test, n1 is the total number of records.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if len(files) > 1 {
+			checkError(fmt.Errorf("no more than one file should be given"))
+		}
+		runtime.GOMAXPROCS(config.NumCPUs)
+
+		method := strings.ToLower(getFlagString(cmd, "method"))
+		switch method {
+		case "ttest", "t-test", "t":
+			method = "ttest"
+		case "mannwhitney", "mann-whitney", "wilcoxon", "u":
+			method = "mannwhitney"
+		case "chisq", "chi-squared":
+			method = "chisq"
+		case "ks", "kolmogorov-smirnov":
+			method = "ks"
+		default:
+			checkError(fmt.Errorf("invalid method: %s. available: ttest, mannwhitney, chisq, ks", method))
+		}
+
+		fieldStr := getFlagString(cmd, "fields")
+		if fieldStr == "" {
+			checkError(fmt.Errorf("flag -f/--fields needed"))
+		}
+		nFields := len(strings.Split(fieldStr, ","))
+		if nFields > 2 {
+			checkError(fmt.Errorf("at most two fields are allowed for -f/--fields"))
+		}
+		groupField := getFlagString(cmd, "group")
+		levels := getFlagStringSlice(cmd, "levels")
+		if len(levels) > 0 && len(levels) != 2 {
+			checkError(fmt.Errorf("exactly two values needed for --levels"))
+		}
+		mu := getFlagFloat64(cmd, "mu")
+		paired := getFlagBool(cmd, "paired")
+		equalVar := getFlagBool(cmd, "equal-var")
+		ignore := getFlagBool(cmd, "ignore-non-numbers")
+		alternative := strings.ToLower(getFlagString(cmd, "alternative"))
+		switch alternative {
+		case "two-sided", "less", "greater":
+		default:
+			checkError(fmt.Errorf("invalid value of -a/--alternative: %s. available: two-sided, less, greater", alternative))
+		}
+		decimalWidth := getFlagNonNegativeInt(cmd, "decimal-width")
+		decimalFormat := fmt.Sprintf("%%.%df", decimalWidth)
+
+		if mu != 0 && method != "ttest" {
+			checkError(fmt.Errorf("flag --mu only works for t-test"))
+		}
+
+		switch method {
+		case "chisq":
+			if nFields != 2 || groupField != "" {
+				checkError(fmt.Errorf("two fields needed for chi-squared test: -f a,b"))
+			}
+		default:
+			if groupField != "" && nFields != 1 {
+				checkError(fmt.Errorf("only one field allowed for -f/--fields when -g/--group given"))
+			}
+			if paired && (method != "ttest" || nFields != 2) {
+				checkError(fmt.Errorf("flag --paired only works for t-test with two fields: -f x,y"))
+			}
+			if method != "ttest" && nFields == 1 && groupField == "" {
+				checkError(fmt.Errorf("two samples needed for method %s: -f x,y or -f value -g group", method))
+			}
+		}
+		if method != "ttest" && method != "mannwhitney" && alternative != "two-sided" {
+			checkError(fmt.Errorf("flag -a/--alternative only works for ttest and mannwhitney"))
+		}
+
+		if groupField != "" {
+			fieldStr = fieldStr + "," + groupField
+		}
+
+		outfh, err := wopenByConfig(config)
+		checkError(err)
+		defer outfh.Close()
+
+		writer := newRecordWriter(config, outfh)
+		defer func() {
+			writer.Flush()
+			checkError(writer.Error())
+		}()
+
+		file := files[0]
+		csvReader, err := newCSVReaderByConfig(config, file)
+
+		if err != nil {
+			if err == xopen.ErrNoContent {
+				if config.Verbose {
+					log.Warningf("csvtk test: skipping empty input file: %s", file)
+				}
+
+				writer.Flush()
+				checkError(writer.Error())
+				readerReport(&config, csvReader, file)
+				return
+			}
+			checkError(err)
+		}
+
+		csvReader.Read(ReadOption{
+			FieldStr: fieldStr,
+
+			DoNotAllowDuplicatedColumnName: true,
+		})
+
+		parse := func(s string, line int) (float64, bool) {
+			if !reDigitals.MatchString(s) {
+				if ignore {
+					return 0, false
+				}
+				checkError(fmt.Errorf("[line %d] non-numeric value: %s, you can use flag -i/--ignore-non-numbers to skip these data", line, s))
+			}
+			v, err := strconv.ParseFloat(removeComma(s), 64)
+			checkError(err)
+			return v, true
+		}
+
+		var names []string
+		var x, y []float64   // two samples
+		var cats [][2]string // for chi-squared test
+		groups := map[string][]float64{}
+		groupsOrder := []string{}
+
+		var v1, v2 float64
+		var ok1, ok2, ok bool
+		checkFirstLine := true
+		for record := range csvReader.Ch {
+			if record.Err != nil {
+				checkError(record.Err)
+			}
+
+			if checkFirstLine {
+				checkFirstLine = false
+				if len(record.Fields) != len(strings.Split(fieldStr, ",")) {
+					checkError(fmt.Errorf("fields should not be ranges or fuzzy fields: %s", fieldStr))
+				}
+				if !config.NoHeaderRow || record.IsHeaderRow {
+					names = append([]string{}, record.Selected...)
+					continue
+				}
+				names = make([]string, len(record.Fields))
+				for i, f := range record.Fields {
+					names[i] = strconv.Itoa(f)
+				}
+			}
+
+			if method == "chisq" {
+				cats = append(cats, [2]string{record.Selected[0], record.Selected[1]})
+				continue
+			}
+
+			if groupField != "" {
+				if v1, ok1 = parse(record.Selected[0], record.Line); !ok1 {
+					continue
+				}
+				if _, ok = groups[record.Selected[1]]; !ok {
+					groupsOrder = append(groupsOrder, record.Selected[1])
+				}
+				groups[record.Selected[1]] = append(groups[record.Selected[1]], v1)
+				continue
+			}
+
+			v1, ok1 = parse(record.Selected[0], record.Line)
+			if nFields == 1 {
+				if ok1 {
+					x = append(x, v1)
+				}
+				continue
+			}
+			v2, ok2 = parse(record.Selected[1], record.Line)
+			if paired {
+				if ok1 && ok2 {
+					x = append(x, v1)
+					y = append(y, v2)
+				}
+				continue
+			}
+			if ok1 {
+				x = append(x, v1)
+			}
+			if ok2 {
+				y = append(y, v2)
+			}
+		}
+
+		readerReport(&config, csvReader, file)
+
+		var sample1, sample2 string
+		if method == "chisq" || groupField == "" {
+			sample1 = names[0]
+			if nFields == 2 {
+				sample2 = names[1]
+			} else {
+				sample2 = fmt.Sprintf("mu=%s", strconv.FormatFloat(mu, 'f', -1, 64))
+			}
+		} else {
+			if len(levels) == 0 {
+				if len(groupsOrder) != 2 {
+					checkError(fmt.Errorf("there should be exactly two groups (%d found), please use --levels to choose two", len(groupsOrder)))
+				}
+				levels = groupsOrder
+			}
+			sample1, sample2 = levels[0], levels[1]
+			for _, g := range levels {
+				if _, ok = groups[g]; !ok {
+					checkError(fmt.Errorf("group not found: %s", g))
+				}
+			}
+			x, y = groups[sample1], groups[sample2]
+		}
+
+		var n1, n2 string
+		var statistic, df, pvalue float64
+		df = math.NaN()
+		switch method {
+		case "ttest":
+			if nFields == 1 && groupField == "" {
+				statistic, df, pvalue = tTestOneSample(x, mu, alternative)
+			} else if paired {
+				d := make([]float64, len(x))
+				for i := range x {
+					d[i] = x[i] - y[i]
+				}
+				statistic, df, pvalue = tTestOneSample(d, mu, alternative)
+			} else {
+				statistic, df, pvalue = tTestTwoSample(x, y, mu, equalVar, alternative)
+			}
+		case "mannwhitney":
+			statistic, pvalue = mannWhitneyU(x, y, alternative)
+		case "ks":
+			statistic, pvalue = ksTwoSample(x, y)
+		case "chisq":
+			statistic, df, pvalue = chiSquaredTest(cats)
+		}
+
+		if method == "chisq" {
+			n1 = strconv.Itoa(len(cats))
+		} else {
+			n1 = strconv.Itoa(len(x))
+			if nFields == 2 || groupField != "" {
+				n2 = strconv.Itoa(len(y))
+			}
+		}
+		var dfStr string
+		if !math.IsNaN(df) {
+			dfStr = strconv.FormatFloat(df, 'f', -1, 64)
+			if df != math.Trunc(df) {
+				dfStr = fmt.Sprintf(decimalFormat, df)
+			}
+		}
+
+		if !config.NoOutHeader {
+			checkError(writer.Write([]string{"method", "sample1", "sample2", "n1", "n2", "statistic", "df", "pvalue"}))
+		}
+		checkError(writer.Write([]string{method, sample1, sample2, n1, n2,
+			fmt.Sprintf(decimalFormat, statistic), dfStr, strconv.FormatFloat(pvalue, 'g', 4, 64)}))
+	},
+}
+
+// pValueT returns the p-value of a t statistic.
+func pValueT(t, df float64, alternative string) float64 {
+	dist := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: df}
+	switch alternative {
+	case "less":
+		return dist.CDF(t)
+	case "greater":
+		return dist.Survival(t)
+	default:
+		return 2 * dist.Survival(math.Abs(t))
+	}
+}
+
+// tTestOneSample returns the t statistic, degrees of freedom, and p-value.
+func tTestOneSample(x []float64, mu float64, alternative string) (float64, float64, float64) {
+	n := float64(len(x))
+	if n < 2 {
+		return math.NaN(), math.NaN(), math.NaN()
+	}
+	mean, sd := stat.MeanStdDev(x, nil)
+	t := (mean - mu) / (sd / math.Sqrt(n))
+	return t, n - 1, pValueT(t, n-1, alternative)
+}
+
+// tTestTwoSample performs Welch's t-test, or Student's t-test if equalVar is true,
+// with mu as the hypothesized difference in means.
+func tTestTwoSample(x, y []float64, mu float64, equalVar bool, alternative string) (float64, float64, float64) {
+	n1, n2 := float64(len(x)), float64(len(y))
+	if n1 < 2 || n2 < 2 {
+		return math.NaN(), math.NaN(), math.NaN()
+	}
+	m1, v1 := stat.MeanVariance(x, nil)
+	m2, v2 := stat.MeanVariance(y, nil)
+	var se, df float64
+	if equalVar {
+		df = n1 + n2 - 2
+		sp2 := ((n1-1)*v1 + (n2-1)*v2) / df
+		se = math.Sqrt(sp2 * (1/n1 + 1/n2))
+	} else {
+		a, b := v1/n1, v2/n2
+		se = math.Sqrt(a + b)
+		df = (a + b) * (a + b) / (a*a/(n1-1) + b*b/(n2-1))
+	}
+	t := (m1 - m2 - mu) / se
+	return t, df, pValueT(t, df, alternative)
+}
+
+// mannWhitneyU returns W (the U statistic of x) and the p-value computed
+// with normal approximation, tie correction and continuity correction,
+// like wilcox.test(x, y, exact = FALSE) in R.
+func mannWhitneyU(x, y []float64, alternative string) (float64, float64) {
+	n1, n2 := float64(len(x)), float64(len(y))
+	if n1 == 0 || n2 == 0 {
+		return math.NaN(), math.NaN()
+	}
+	all := make([]float64, 0, len(x)+len(y))
+	all = append(all, x...)
+	all = append(all, y...)
+	ranks := rankValues(all)
+
+	var r1 float64
+	for i := range x {
+		r1 += ranks[i]
+	}
+	w := r1 - n1*(n1+1)/2
+
+	// tie correction
+	sorted := append([]float64{}, all...)
+	sort.Float64s(sorted)
+	var ties float64
+	var j int
+	for i := 0; i < len(sorted); i = j {
+		for j = i + 1; j < len(sorted) && sorted[j] == sorted[i]; j++ {
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+	}
+	n := n1 + n2
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1))))
+	if sigma == 0 {
+		return w, math.NaN()
+	}
+
+	z := w - n1*n2/2
+	var correction float64
+	switch alternative {
+	case "less":
+		correction = -0.5
+	case "greater":
+		correction = 0.5
+	default:
+		if z > 0 {
+			correction = 0.5
+		} else if z < 0 {
+			correction = -0.5
+		}
+	}
+	z = (z - correction) / sigma
+
+	switch alternative {
+	case "less":
+		return w, distuv.UnitNormal.CDF(z)
+	case "greater":
+		return w, distuv.UnitNormal.Survival(z)
+	default:
+		return w, 2 * math.Min(distuv.UnitNormal.CDF(z), distuv.UnitNormal.Survival(z))
+	}
+}
+
+// ksTwoSample returns the D statistic of the two-sample Kolmogorov-Smirnov test
+// and the p-value of the asymptotic distribution.
+func ksTwoSample(x, y []float64) (float64, float64) {
+	n1, n2 := len(x), len(y)
+	if n1 == 0 || n2 == 0 {
+		return math.NaN(), math.NaN()
+	}
+	a := append([]float64{}, x...)
+	b := append([]float64{}, y...)
+	sort.Float64s(a)
+	sort.Float64s(b)
+
+	var i, j int
+	var d, v float64
+	for i < n1 && j < n2 {
+		v = math.Min(a[i], b[j])
+		for i < n1 && a[i] == v {
+			i++
+		}
+		for j < n2 && b[j] == v {
+			j++
+		}
+		d = math.Max(d, math.Abs(float64(i)/float64(n1)-float64(j)/float64(n2)))
+	}
+
+	ne := float64(n1) * float64(n2) / float64(n1+n2)
+	lambda := (math.Sqrt(ne) + 0.12 + 0.11/math.Sqrt(ne)) * d
+	return d, kolmogorovQ(lambda)
+}
+
+// kolmogorovQ is the survival function of the Kolmogorov distribution.
+func kolmogorovQ(lambda float64) float64 {
+	if lambda < 1e-3 {
+		return 1
+	}
+	var sum, term float64
+	sign := 1.0
+	for k := 1; k <= 100; k++ {
+		term = sign * math.Exp(-2*float64(k*k)*lambda*lambda)
+		sum += term
+		if math.Abs(term) < 1e-12 {
+			break
+		}
+		sign = -sign
+	}
+	p := 2 * sum
+	if p < 0 {
+		return 0
+	}
+	if p > 1 {
+		return 1
+	}
+	return p
+}
+
+// chiSquaredTest performs the chi-squared test of independence,
+// returning the statistic, degrees of freedom and p-value.
+func chiSquaredTest(pairs [][2]string) (float64, float64, float64) {
+	rows := make(map[string]float64)
+	cols := make(map[string]float64)
+	counts := make(map[[2]string]float64)
+	for _, p := range pairs {
+		rows[p[0]]++
+		cols[p[1]]++
+		counts[p]++
+	}
+	if len(rows) < 2 || len(cols) < 2 {
+		return math.NaN(), math.NaN(), math.NaN()
+	}
+	n := float64(len(pairs))
+	var chi2, e, o float64
+	for r, nr := range rows {
+		for c, nc := range cols {
+			e = nr * nc / n
+			o = counts[[2]string{r, c}]
+			chi2 += (o - e) * (o - e) / e
+		}
+	}
+	df := float64((len(rows) - 1) * (len(cols) - 1))
+	return chi2, df, distuv.ChiSquared{K: df}.Survival(chi2)
+}
+
+func init() {
+	RootCmd.AddCommand(statTestCmd)
+
+	statTestCmd.Flags().StringP("method", "m", "ttest", `test method: ttest, mannwhitney, chisq, ks`)
+	statTestCmd.Flags().StringP("fields", "f", "", `one or two fields. e.g -f 1,2 or -f columnA,columnB`)
+	statTestCmd.Flags().StringP("group", "g", "", `group field for splitting values of one field into two samples`)
+	statTestCmd.Flags().StringSliceP("levels", "", []string{}, `two values of the group field to compare, e.g., --levels A,B`)
+	statTestCmd.Flags().Float64P("mu", "", 0, `the hypothesized mean for one-sample t-test, or difference in means for two-sample and paired t-tests`)
+	statTestCmd.Flags().BoolP("paired", "p", false, `paired t-test`)
+	statTestCmd.Flags().BoolP("equal-var", "e", false, `assume equal variances for two-sample t-test (Student's t-test)`)
+	statTestCmd.Flags().StringP("alternative", "a", "two-sided", `alternative hypothesis: two-sided, less, greater. for ttest and mannwhitney`)
+	statTestCmd.Flags().BoolP("ignore-non-numbers", "i", false, `ignore non-numeric values like "NA" or "N/A"`)
+	statTestCmd.Flags().IntP("decimal-width", "w", 4, "limit floats to N decimal points")
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// the sleep data in R
+var sleepExtra = [2][]float64{
+	{0.7, -1.6, -0.2, -1.2, -0.1, 3.4, 3.7, 0.8, 0.0, 2.0},
+	{1.9, 0.8, 1.1, 0.1, -0.1, 4.4, 5.5, 1.6, 4.6, 3.4},
+}
+
+// roughlyEqual checks if a is equal to b, which is rounded to some significant digits.
+func roughlyEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 5e-4*math.Abs(b)
+}
+
+func TestTTest(t *testing.T) {
+	x, y := sleepExtra[0], sleepExtra[1]
+
+	// t.test(extra ~ group, data = sleep) in R
+	statistic, df, pvalue := tTestTwoSample(x, y, 0, false, "two-sided")
+	if !roughlyEqual(statistic, -1.8608) || !roughlyEqual(df, 17.7765) || !roughlyEqual(pvalue, 0.07939) {
+		t.Errorf("tTestTwoSample (Welch): got %v, %v, %v", statistic, df, pvalue)
+	}
+
+	// t.test(extra ~ group, data = sleep, var.equal = TRUE)
+	statistic, df, pvalue = tTestTwoSample(x, y, 0, true, "two-sided")
+	if !roughlyEqual(statistic, -1.8608) || df != 18 || !roughlyEqual(pvalue, 0.07919) {
+		t.Errorf("tTestTwoSample (Student): got %v, %v, %v", statistic, df, pvalue)
+	}
+
+	// mu is the hypothesized difference in means, the same as shifting y
+	for _, equalVar := range []bool{false, true} {
+		for _, alternative := range []string{"two-sided", "less", "greater"} {
+			y2 := make([]float64, len(y))
+			for i, v := range y {
+				y2[i] = v - 1
+			}
+			t1, df1, p1 := tTestTwoSample(x, y, -1, equalVar, alternative)
+			t2, df2, p2 := tTestTwoSample(x, y2, 0, equalVar, alternative)
+			if !floatsClose(t1, t2) || !floatsClose(df1, df2) || !floatsClose(p1, p2) {
+				t.Errorf("tTestTwoSample (mu: -1, equalVar: %v, %s): got %v, %v, %v, want %v, %v, %v",
+					equalVar, alternative, t1, df1, p1, t2, df2, p2)
+			}
+		}
+	}
+
+	// t.test(extra ~ group, data = sleep, paired = TRUE)
+	d := make([]float64, len(x))
+	for i := range x {
+		d[i] = x[i] - y[i]
+	}
+	statistic, df, pvalue = tTestOneSample(d, 0, "two-sided")
+	if !roughlyEqual(statistic, -4.0621) || df != 9 || !roughlyEqual(pvalue, 0.002833) {
+		t.Errorf("tTestOneSample (paired): got %v, %v, %v", statistic, df, pvalue)
+	}
+
+	statistic, _, _ = tTestOneSample([]float64{1}, 0, "two-sided")
+	if !math.IsNaN(statistic) {
+		t.Errorf("tTestOneSample (n=1): want NaN, got %v", statistic)
+	}
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	// wilcox.test(extra ~ group, data = sleep, exact = FALSE)
+	w, pvalue := mannWhitneyU(sleepExtra[0], sleepExtra[1], "two-sided")
+	if w != 25.5 || !roughlyEqual(pvalue, 0.06933) {
+		t.Errorf("mannWhitneyU: got %v, %v", w, pvalue)
+	}
+}
+
+func TestStatTest(t *testing.T) {
+	// sex is fake, for the chi-squared test
+	var sb strings.Builder
+	sb.WriteString("id,group,extra,sex\n")
+	for g, values := range sleepExtra {
+		for i, v := range values {
+			sex := "F"
+			if v > 1 {
+				sex = "M"
+			}
+			sb.WriteString(strings.Join([]string{
+				strings.Repeat("i", i+1), []string{"A", "B"}[g],
+				strconv.FormatFloat(v, 'f', -1, 64), sex}, ","))
+			sb.WriteString("\n")
+		}
+	}
+	file := testFile(t, "sleep.csv", sb.String())
+	fileNA := testFile(t, "sleepNA.csv", sb.String()+"x,A,NA,F\n")
+
+	header := "method,sample1,sample2,n1,n2,statistic,df,pvalue\n"
+	cases := []struct {
+		args   []string
+		expect string
+	}{
+		{
+			[]string{"-f", "extra", "-g", "group", "--levels", "A,B"},
+			"ttest,A,B,10,10,-1.8608,17.7765,0.07939\n",
+		},
+		{
+			[]string{"-f", "extra", "-g", "group", "--levels", "A,B", "--mu", "-1"},
+			"ttest,A,B,10,10,-0.6831,17.7765,0.5034\n",
+		},
+		{
+			[]string{"-f", "extra", "-g", "group", "--levels", "A,B", "-e", "-a", "less", "-w", "2"},
+			"ttest,A,B,10,10,-1.86,18,0.03959\n",
+		},
+		{
+			[]string{"-f", "extra", "-g", "group", "--levels", "A,B", "-m", "u"},
+			"mannwhitney,A,B,10,10,25.5000,,0.06933\n",
+		},
+		{
+			[]string{"-f", "extra", "-g", "group", "--levels", "B,A", "-m", "ks", "-w", "1"},
+			"ks,B,A,10,10,0.4,,0.3129\n",
+		},
+		{
+			[]string{"-f", "group,sex", "-m", "chisq", "-w", "3"},
+			"chisq,group,sex,20,,3.200,1,0.07364\n",
+		},
+	}
+	for _, c := range cases {
+		got := runCsvtk(t, append([]string{"test", file}, c.args...)...)
+		if got != header+c.expect {
+			t.Errorf("csvtk test %s:\nwant %q\ngot  %q", strings.Join(c.args, " "), header+c.expect, got)
+		}
+	}
+
+	// errors
+	for _, args := range [][]string{
+		{"-f", "extra", "-g", "id"},                                                // more than two groups
+		{"-f", "extra", "-g", "group", "--levels", "A,C"},                          // group not found
+		{"-f", "extra", "-g", "group", "--levels", "A,B", "-m", "ks", "--mu", "1"}, // mu for ks
+		{"-f", "extra", "-g", "group", "--levels", "A,B", "-p"},                    // paired with a group field
+	} {
+		if _, err := execCsvtkInSubprocess(t, append([]string{"test", file}, args...)...); err == nil {
+			t.Errorf("csvtk test %s: want error, got nil", strings.Join(args, " "))
+		}
+	}
+	// non-numeric values
+	if _, err := execCsvtkInSubprocess(t, "test", fileNA, "-f", "extra", "-g", "group"); err == nil {
+		t.Errorf("csvtk test with NA: want error, got nil")
+	}
+	got := runCsvtk(t, "test", fileNA, "-f", "extra", "-g", "group", "-i")
+	if want := header + cases[0].expect; got != want {
+		t.Errorf("csvtk test -i:\nwant %q\ngot  %q", want, got)
+	}
+}
@@ -21,10 +21,10 @@
 package cmd
 
 import (
-	"encoding/csv"
 	"fmt"
 	"math"
 	"math/rand"
+	"regexp"
 	"runtime"
 	"sort"
 	"strconv"
@@ -34,6 +34,7 @@ import (
 	"github.com/spf13/cobra"
 	"gonum.org/v1/gonum/floats"
 	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
 )
 
 var separater string
@@ -59,9 +60,45 @@ Available operations:
   mean, stdev, variance, median, q1, q2, q3,
   entropy (Shannon entropy), 
   prod (product of the elements)
+  pN (N-th percentile, N in [0, 100], e.g., p10, p99.9),
+  iqr (interquartile range, q3 - q1),
+  mad (median absolute deviation, not scaled),
+  mode (the most frequent value, the smallest one for ties),
+  skewness, kurtosis (excess kurtosis),
+  gmean (geometric mean), hmean (harmonic mean),
+  cv (coefficient of variation, stdev / mean),
+  se (standard error of the mean),
+  ciN_lower, ciN_upper (N% confidence interval of the mean using
+    Student's t-distribution, N in (0, 100), e.g., ci95_lower, ci95_upper)
 
   # textual/numeric operations
-  count, first, last, rand, unique/uniq, collapse, countunique
+  count, first, last, rand, unique/uniq, collapse, countunique,
+  countna (count NA values given by --na-values, case ignored)
+
+  Only values of operations below are stored in memory, others are
+  computed incrementally with bounded memory:
+    median, q1, q2, q3, pN, iqr, mad, mode, skewness, kurtosis,
+    ciN_lower, ciN_upper, rand, unique/uniq, collapse, countunique
+
+  # subtotals of groups
+  --rollup    besides groups of all group fields, also output subtotals
+              of groups of leading group fields and the grand total,
+              e.g., for "-g a,b": (a, b), (a), and ().
+  --cube      output subtotals of all combinations of group fields,
+              e.g., for "-g a,b": (a, b), (a), (b), and ().
+  Values of rolled up group fields are replaced with --all-label, and
+  subtotal records are placed after records of detailed groups.
+
+  # approximate operations, using bounded memory for huge data
+  approx_countunique  approximate number of unique values (HyperLogLog),
+                      exact for no more than 1024 unique values,
+                      standard error: 0.81%
+  approx_topN         N approximately most frequent values and their counts
+                      (Count-Min sketch), e.g., approx_top10. Values are
+                      outputted as "value:count" joined by -s/--separater
+  approx_qN           approximate N-th percentile (t-digest), N in [0, 100],
+                      e.g., approx_q95, approx_q99.9. Exact for small data
+  approx_median       approximate median (t-digest)
 
 `,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -78,10 +115,23 @@ Available operations:
 		decimalFormatScientificE := fmt.Sprintf("%%.%dE", decimalWidth)
 		decimalFormatScientifice := fmt.Sprintf("%%.%de", decimalWidth)
 		groupsStr := getFlagString(cmd, "groups")
+		rollup := getFlagBool(cmd, "rollup")
+		cube := getFlagBool(cmd, "cube")
+		allLabel := getFlagString(cmd, "all-label")
+		if rollup && cube {
+			checkError(fmt.Errorf("flags --rollup and --cube are exclusive"))
+		}
+		if (rollup || cube) && groupsStr == "" {
+			checkError(fmt.Errorf("flag -g/--groups needed for --rollup or --cube"))
+		}
 		separater = getFlagString(cmd, "separater")
 		if separater == "" {
 			checkError(fmt.Errorf("flag -s (--separater) needed"))
 		}
+		naValues = make(map[string]struct{}, 8)
+		for _, na := range getFlagStringSlice(cmd, "na-values") {
+			naValues[strings.ToLower(na)] = struct{}{}
+		}
 		seed := getFlagInt64(cmd, "rand-seed")
 		rand.Seed(seed)
 
@@ -144,9 +194,10 @@ Available operations:
 					_fields = append(_fields, items[0])
 				}
 
-				_, ok1 := allStats[items[1]]  // for numbers
-				_, ok2 := allStats2[items[1]] // for strings
-				if !(ok1 || ok2) {
+				_, ok1 := getStatFunc(items[1])   // for numbers
+				_, ok2 := allStats2[items[1]]     // for strings
+				_, ok3 := parseApproxOp(items[1]) // approximate operations
+				if !(ok1 || ok2 || ok3) {
 					checkError(fmt.Errorf(`invalid operation: %s. run "csvtk summary --help" for help`, items[1]))
 				}
 
@@ -179,20 +230,11 @@ Available operations:
 
 		fieldsStr := strings.Join(tmp, ",")
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
-		writer := csv.NewWriter(outfh)
-		if config.OutTabs || config.Tabs {
-			if config.OutDelimiter == ',' {
-				writer.Comma = '\t'
-			} else {
-				writer.Comma = config.OutDelimiter
-			}
-		} else {
-			writer.Comma = config.OutDelimiter
-		}
+		writer := newRecordWriter(config, outfh)
 		defer func() {
 			writer.Flush()
 			checkError(writer.Error())
@@ -227,6 +269,15 @@ Available operations:
 		data := make(map[string]map[int][]float64) // for numbers
 		data2 := make(map[string]map[int][]string) // for strings
 		scientifc := make(map[string]map[int]byte) // for numbers
+		sketches := make(map[string]map[int]*approxSketch)
+		accumulators := make(map[string]map[int]*summaryAccumulator)
+
+		// what to do with values of each field
+		storeStrings := make(map[int]bool) // for textual operations needing all values
+		parseNumbers := make(map[int]bool) // for numeric, streaming numeric, and approximate numeric operations
+		storeNumbers := make(map[int]bool) // for numeric operations needing all values
+		accumulate := make(map[int]bool)   // for streaming operations
+		approxOps := make(map[int][]approxOp)
 
 		fieldsG := []int{}
 		fieldsD := []int{}
@@ -236,7 +287,37 @@ Available operations:
 		var e error
 		var ok bool
 		var group string
-		var needParseDigits bool
+		var sketch *approxSketch
+		var acc *summaryAccumulator
+
+		// grouping sets: whether to keep values of group fields
+		groupingSets := [][]bool{}
+		numFieldsG := len(fieldsStrsG)
+		switch {
+		case cube:
+			for mask := 0; mask < 1<<numFieldsG; mask++ {
+				keep := make([]bool, numFieldsG)
+				for i := range keep {
+					keep[i] = mask&(1<<i) == 0
+				}
+				groupingSets = append(groupingSets, keep)
+			}
+		case rollup:
+			for n := numFieldsG; n >= 0; n-- {
+				keep := make([]bool, numFieldsG)
+				for i := 0; i < n; i++ {
+					keep[i] = true
+				}
+				groupingSets = append(groupingSets, keep)
+			}
+		default:
+			keep := make([]bool, numFieldsG)
+			for i := range keep {
+				keep[i] = true
+			}
+			groupingSets = append(groupingSets, keep)
+		}
+		groupValues := make([]string, numFieldsG)
 
 		var hasHeaderLine bool
 		checkFirstLine := true
@@ -264,6 +345,27 @@ Available operations:
 					}
 				}
 
+				for f, ops := range statsI {
+					for _, op := range ops {
+						if _, ok = streamStats[op]; ok {
+							parseNumbers[f] = true
+							accumulate[f] = true
+						} else if _, ok = streamStats2[op]; ok {
+							accumulate[f] = true
+						} else if _, ok = allStats[op]; ok {
+							parseNumbers[f] = true
+							storeNumbers[f] = true
+						} else if _, ok = allStats2[op]; ok {
+							storeStrings[f] = true
+						} else if a, _ := parseApproxOp(op); a.kind == approxQuantile {
+							parseNumbers[f] = true
+							approxOps[f] = append(approxOps[f], a)
+						} else {
+							approxOps[f] = append(approxOps[f], a)
+						}
+					}
+				}
+
 				if !config.NoHeaderRow || record.IsHeaderRow {
 					HeaderRow = record.All
 					hasHeaderLine = true
@@ -271,50 +373,84 @@ Available operations:
 				}
 			}
 
-			group = strings.Join(record.Selected[numFieldsD:], "_shenwei356_")
-			if _, ok = data[group]; !ok {
-				data[group] = make(map[int][]float64, 1024)
-				scientifc[group] = make(map[int]byte)
-			}
-			if _, ok = data2[group]; !ok {
-				data2[group] = make(map[int][]string, 1024)
-			}
-
-			for _, f = range fieldsDUniq {
-				if _, ok = data2[group][f]; !ok {
-					data2[group][f] = []string{}
+			for _, keep := range groupingSets {
+				for i, v := range record.Selected[numFieldsD:] {
+					if keep[i] {
+						groupValues[i] = v
+					} else {
+						groupValues[i] = summaryAllMarker
+					}
 				}
-				data2[group][f] = append(data2[group][f], record.All[f-1])
-
-				needParseDigits = false
-				for _, op := range statsI[f] {
-					if _, ok = allStats[op]; ok {
-						needParseDigits = true
-						break
+				group = strings.Join(groupValues, "_shenwei356_")
+				// values are only buffered for fields with operations needing all values,
+				// while data also records all groups.
+				if _, ok = data[group]; !ok {
+					data[group] = nil
+					if len(storeNumbers) > 0 {
+						data[group] = make(map[int][]float64, len(storeNumbers))
 					}
+					scientifc[group] = make(map[int]byte)
 				}
-
-				if !needParseDigits {
-					continue
+				if len(storeStrings) > 0 {
+					if _, ok = data2[group]; !ok {
+						data2[group] = make(map[int][]string, len(storeStrings))
+					}
 				}
-				if !reDigitals.MatchString(record.All[f-1]) {
-					if ignore {
-						continue
+				if _, ok = sketches[group]; !ok {
+					sketches[group] = make(map[int]*approxSketch, len(approxOps))
+					for f, ops := range approxOps {
+						sketches[group][f] = newApproxSketch(ops)
 					}
-					checkError(fmt.Errorf("column %d has non-numeric data: %s, you can use flag -i/--ignore-non-numbers to skip these data", f, record.All[f-1]))
 				}
-				if strings.Contains(record.All[f-1], "E") {
-					scientifc[group][f] = 'E'
-				} else if strings.Contains(record.All[f-1], "e") {
-					scientifc[group][f] = 'e'
+				if _, ok = accumulators[group]; !ok {
+					accumulators[group] = make(map[int]*summaryAccumulator, len(accumulate))
+					for f = range accumulate {
+						accumulators[group][f] = newSummaryAccumulator()
+					}
 				}
 
-				v, e = strconv.ParseFloat(removeComma(record.All[f-1]), 64)
-				checkError(e)
-				if _, ok = data[group][f]; !ok {
-					data[group][f] = []float64{}
+				for _, f = range fieldsDUniq {
+					if storeStrings[f] {
+						data2[group][f] = append(data2[group][f], record.All[f-1])
+					}
+
+					acc = accumulators[group][f]
+					if acc != nil {
+						acc.addString(record.All[f-1])
+					}
+
+					sketch = sketches[group][f]
+					if sketch != nil {
+						sketch.addString(record.All[f-1])
+					}
+
+					if !parseNumbers[f] {
+						continue
+					}
+					if !reDigitals.MatchString(record.All[f-1]) {
+						if ignore {
+							continue
+						}
+						checkError(fmt.Errorf("column %d has non-numeric data: %s, you can use flag -i/--ignore-non-numbers to skip these data", f, record.All[f-1]))
+					}
+					if strings.Contains(record.All[f-1], "E") {
+						scientifc[group][f] = 'E'
+					} else if strings.Contains(record.All[f-1], "e") {
+						scientifc[group][f] = 'e'
+					}
+
+					v, e = strconv.ParseFloat(removeComma(record.All[f-1]), 64)
+					checkError(e)
+					if storeNumbers[f] {
+						data[group][f] = append(data[group][f], v)
+					}
+					if acc != nil {
+						acc.addNumber(v)
+					}
+					if sketch != nil && sketch.tdigest != nil {
+						sketch.tdigest.Add(v)
+					}
 				}
-				data[group][f] = append(data[group][f], v)
 			}
 
 		}
@@ -341,14 +477,23 @@ Available operations:
 		for group := range data {
 			groups = append(groups, group)
 		}
-		sort.Strings(groups)
+		if rollup || cube {
+			sortGroupsWithSubtotals(groups)
+		} else {
+			sort.Strings(groups)
+		}
 
 		var fu func([]float64) float64
 		var fu2 func([]string) string
 		for _, group := range groups {
 			record := make([]string, 0, colsOut)
 			if len(fieldsG) > 0 {
-				record = append(record, strings.Split(group, "_shenwei356_")...)
+				for _, v := range strings.Split(group, "_shenwei356_") {
+					if v == summaryAllMarker {
+						v = allLabel
+					}
+					record = append(record, v)
+				}
 			}
 
 			for i, ss := range statsList {
@@ -356,13 +501,51 @@ Available operations:
 				f := fieldsD[i]
 
 				sorted := false
-				if _, ok = allStats[s]; !ok {
+				if a, isApprox := parseApproxOp(s); isApprox {
+					sketch = sketches[group][f]
+					switch a.kind {
+					case approxCountUnique:
+						record = append(record, strconv.FormatUint(sketch.hll.Count(), 10))
+					case approxTop:
+						top := sketch.heavyHitters.Top()
+						if len(top) > a.n {
+							top = top[:a.n]
+						}
+						items := make([]string, len(top))
+						for j, kc := range top {
+							items[j] = kc.Key + ":" + strconv.FormatUint(kc.Count, 10)
+						}
+						record = append(record, strings.Join(items, separater))
+					case approxQuantile:
+						v = sketch.tdigest.Quantile(a.q)
+						if scientifc[group][f] == 'E' {
+							record = append(record, fmt.Sprintf(decimalFormatScientificE, v))
+						} else if scientifc[group][f] == 'e' {
+							record = append(record, fmt.Sprintf(decimalFormatScientifice, v))
+						} else {
+							record = append(record, fmt.Sprintf(decimalFormat, v))
+						}
+					}
+				} else if fs, ok := streamStats2[s]; ok {
+					record = append(record, fs(accumulators[group][f]))
+				} else if _, ok = allStats[s]; !ok {
 					fu2 = allStats2[s]
 					record = append(record, fu2(data2[group][f]))
+				} else if fs, ok := streamStats[s]; ok {
+					v = fs(accumulators[group][f])
+					if s == "countn" {
+						record = append(record, fmt.Sprintf("%.0f", v))
+					} else if scientifc[group][f] == 'E' {
+						record = append(record, fmt.Sprintf(decimalFormatScientificE, v))
+					} else if scientifc[group][f] == 'e' {
+						record = append(record, fmt.Sprintf(decimalFormatScientifice, v))
+					} else {
+						record = append(record, fmt.Sprintf(decimalFormat, v))
+					}
 				} else {
 					needSort := false
 					for _, s := range statsI[f] {
-						if s == "q1" || s == "q2" || s == "q3" || s == "median" {
+						if statsNeedSort[s] {
 							needSort = true
 							break
 						}
@@ -373,9 +556,7 @@ Available operations:
 					}
 
 					fu = allStats[s]
-					if s == "countn" {
-						record = append(record, fmt.Sprintf("%.0f", fu(data[group][f])))
-					} else if scientifc[group][f] == 'E' {
+					if scientifc[group][f] == 'E' {
 						record = append(record, fmt.Sprintf(decimalFormatScientificE, fu(data[group][f])))
 					} else if scientifc[group][f] == 'e' {
 						record = append(record, fmt.Sprintf(decimalFormatScientifice, fu(data[group][f])))
@@ -394,6 +575,68 @@ var allStats map[string]func([]float64) float64
 var allStats2 map[string]func([]string) string
 var allStatsList []string
 
+// streamStats and streamStats2 are operations computed incrementally
+// with summaryAccumulator, without storing all values.
+var streamStats map[string]func(*summaryAccumulator) float64
+var streamStats2 map[string]func(*summaryAccumulator) string
+
+// statsNeedSort marks numeric operations requiring sorted data.
+var statsNeedSort = map[string]bool{"median": true, "q1": true, "q2": true, "q3": true, "iqr": true, "mad": true}
+
+// naValues are NA values in lower case, for operation "countna".
+var naValues map[string]struct{}
+
+var reStatPercentile = regexp.MustCompile(`^p(\d+(\.\d+)?)$`)
+var reStatCI = regexp.MustCompile(`^ci(\d+(\.\d+)?)_(lower|upper)$`)
+
+// getStatFunc returns the function of a numeric operation. Operations with
+// parameters, i.e., pN, ciN_lower and ciN_upper, are added to allStats.
+func getStatFunc(op string) (func([]float64) float64, bool) {
+	if fu, ok := allStats[op]; ok {
+		return fu, true
+	}
+
+	if m := reStatPercentile.FindStringSubmatch(op); m != nil {
+		p, err := strconv.ParseFloat(m[1], 64)
+		if err != nil || p > 100 {
+			return nil, false
+		}
+		p /= 100
+		allStats[op] = func(s []float64) float64 {
+			if len(s) == 0 {
+				return math.NaN()
+			}
+			return percentileValue(s, p)
+		}
+		statsNeedSort[op] = true
+		return allStats[op], true
+	}
+
+	if m := reStatCI.FindStringSubmatch(op); m != nil {
+		c, err := strconv.ParseFloat(m[1], 64)
+		if err != nil || c <= 0 || c >= 100 {
+			return nil, false
+		}
+		c /= 100
+		upper := m[3] == "upper"
+		allStats[op] = func(s []float64) float64 {
+			if len(s) < 2 {
+				return math.NaN()
+			}
+			mean, std := stat.MeanStdDev(s, nil)
+			t := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: float64(len(s) - 1)}.Quantile(1 - (1-c)/2)
+			d := t * std / math.Sqrt(float64(len(s)))
+			if upper {
+				return mean + d
+			}
+			return mean - d
+		}
+		return allStats[op], true
+	}
+
+	return nil, false
+}
+
 func init() {
 	allStats = make(map[string]func([]float64) float64)
 	allStats["sum"] = func(s []float64) float64 {
@@ -456,6 +699,65 @@ func init() {
 		}
 		return percentileValue(s, 0.75)
 	}
+	allStats["iqr"] = func(s []float64) float64 {
+		if len(s) == 0 {
+			return math.NaN()
+		}
+		return percentileValue(s, 0.75) - percentileValue(s, 0.25)
+	}
+	allStats["mad"] = func(s []float64) float64 {
+		if len(s) == 0 {
+			return math.NaN()
+		}
+		m := median(s)
+		d := make([]float64, len(s))
+		for i, v := range s {
+			d[i] = math.Abs(v - m)
+		}
+		sort.Float64s(d)
+		return median(d)
+	}
+	allStats["mode"] = func(s []float64) float64 {
+		if len(s) == 0 {
+			return math.NaN()
+		}
+		counts := make(map[float64]int, len(s))
+		for _, v := range s {
+			counts[v]++
+		}
+		var mode float64
+		var max int
+		for v, c := range counts {
+			if c > max || (c == max && v < mode) {
+				mode, max = v, c
+			}
+		}
+		return mode
+	}
+	allStats["skewness"] = func(s []float64) float64 { return stat.Skew(s, nil) }
+	allStats["kurtosis"] = func(s []float64) float64 { return stat.ExKurtosis(s, nil) }
+	allStats["gmean"] = func(s []float64) float64 {
+		if len(s) == 0 {
+			return math.NaN()
+		}
+		return stat.GeometricMean(s, nil)
+	}
+	allStats["hmean"] = func(s []float64) float64 {
+		if len(s) == 0 {
+			return math.NaN()
+		}
+		return stat.HarmonicMean(s, nil)
+	}
+	allStats["cv"] = func(s []float64) float64 {
+		mean, std := stat.MeanStdDev(s, nil)
+		return std / mean
+	}
+	allStats["se"] = func(s []float64) float64 {
+		if len(s) == 0 {
+			return math.NaN()
+		}
+		return stat.StdDev(s, nil) / math.Sqrt(float64(len(s)))
+	}
 
 	allStats2 = make(map[string]func([]string) string)
 	allStats2["count"] = func(s []string) string { return fmt.Sprintf("%d", len(s)) }
@@ -485,6 +787,86 @@ func init() {
 	}
 	allStats2["countuniq"] = allStats2["countunique"]
 	allStats2["collapse"] = func(s []string) string { return strings.Join(s, separater) }
+	allStats2["countna"] = func(s []string) string {
+		var n int
+		var ok bool
+		for _, v := range s {
+			if _, ok = naValues[strings.ToLower(v)]; ok {
+				n++
+			}
+		}
+		return fmt.Sprintf("%d", n)
+	}
+
+	streamStats = make(map[string]func(*summaryAccumulator) float64)
+	streamStats["countn"] = func(a *summaryAccumulator) float64 { return float64(a.n) }
+	streamStats["sum"] = func(a *summaryAccumulator) float64 {
+		if a.n == 0 {
+			return math.NaN()
+		}
+		return a.sum
+	}
+	streamStats["max"] = func(a *summaryAccumulator) float64 {
+		if a.n == 0 {
+			return math.NaN()
+		}
+		return a.max
+	}
+	streamStats["min"] = func(a *summaryAccumulator) float64 {
+		if a.n == 0 {
+			return math.NaN()
+		}
+		return a.min
+	}
+	streamStats["argmax"] = func(a *summaryAccumulator) float64 {
+		if a.n == 0 {
+			return math.NaN()
+		}
+		return float64(a.argmax)
+	}
+	streamStats["argmin"] = func(a *summaryAccumulator) float64 {
+		if a.n == 0 {
+			return math.NaN()
+		}
+		return float64(a.argmin)
+	}
+	streamStats["prod"] = func(a *summaryAccumulator) float64 { return a.prod }
+	streamStats["mean"] = func(a *summaryAccumulator) float64 {
+		if a.n == 0 {
+			return math.NaN()
+		}
+		return a.mean
+	}
+	streamStats["variance"] = func(a *summaryAccumulator) float64 { return a.variance() }
+	streamStats["stdev"] = func(a *summaryAccumulator) float64 { return math.Sqrt(a.variance()) }
+	streamStats["se"] = func(a *summaryAccumulator) float64 {
+		return math.Sqrt(a.variance()) / math.Sqrt(float64(a.n))
+	}
+	streamStats["cv"] = func(a *summaryAccumulator) float64 {
+		if a.n == 0 {
+			return math.NaN()
+		}
+		return math.Sqrt(a.variance()) / a.mean
+	}
+	streamStats["gmean"] = func(a *summaryAccumulator) float64 {
+		if a.n == 0 {
+			return math.NaN()
+		}
+		return math.Exp(a.sumLog / float64(a.n))
+	}
+	streamStats["hmean"] = func(a *summaryAccumulator) float64 {
+		if a.n == 0 {
+			return math.NaN()
+		}
+		return float64(a.n) / a.sumInv
+	}
+	streamStats["entropy"] = func(a *summaryAccumulator) float64 { return a.entropy }
+
+	streamStats2 = make(map[string]func(*summaryAccumulator) string)
+	streamStats2["count"] = func(a *summaryAccumulator) string { return fmt.Sprintf("%d", a.count) }
+	streamStats2["first"] = func(a *summaryAccumulator) string { return a.first }
+	streamStats2["last"] = func(a *summaryAccumulator) string { return a.last }
+	streamStats2["countna"] = func(a *summaryAccumulator) string { return fmt.Sprintf("%d", a.countNA) }
 
 	// ---------------
 
@@ -495,6 +877,8 @@ func init() {
 	for k := range allStats2 {
 		allStatsList = append(allStatsList, k)
 	}
+	allStatsList = append(allStatsList, "pN", "ciN_lower", "ciN_upper")
+	allStatsList = append(allStatsList, "approx_countunique", "approx_median", "approx_qN", "approx_topN")
 	sort.Strings(allStatsList)
 
 	RootCmd.AddCommand(summaryCmd)
@@ -503,6 +887,10 @@ func init() {
 	summaryCmd.Flags().BoolP("ignore-non-numbers", "i", false, `ignore non-numeric values like "NA" or "N/A"`)
 	summaryCmd.Flags().IntP("decimal-width", "w", 2, "limit floats to N decimal points")
 	summaryCmd.Flags().StringP("separater", "s", "; ", "separater for collapsed data")
+	summaryCmd.Flags().BoolP("rollup", "", false, `also output subtotals of leading group fields and the grand total`)
+	summaryCmd.Flags().BoolP("cube", "", false, `also output subtotals of all combinations of group fields`)
+	summaryCmd.Flags().StringP("all-label", "", "all", `label of rolled up group fields, for --rollup and --cube`)
+	summaryCmd.Flags().StringSliceP("na-values", "", []string{"", "NA", "N/A"}, `NA values for operation "countna", case ignored`)
 	summaryCmd.Flags().Int64P("rand-seed", "S", 11, `rand seed for operation "rand"`)
 }
 
@@ -534,5 +922,180 @@ func percentileValue(sorted []float64, percentile float64) float64 {
 
 	h := float64(l-1) * percentile
 	fh := math.Floor(h)
+	if int(fh) >= l-1 {
+		return sorted[l-1]
+	}
 	return sorted[int(fh)] + (h-fh)*(sorted[int(fh)+1]-sorted[int(fh)])
 }
+
+const (
+	approxCountUnique = iota
+	approxTop
+	approxQuantile
+)
+
+// approxOp is an approximate operation.
+type approxOp struct {
+	kind int
+	n    int     // for approx_topN
+	q    float64 // for approx_qN, in [0, 1]
+}
+
+var reApproxTop = regexp.MustCompile(`^approx_top(\d+)$`)
+var reApproxQuantile = regexp.MustCompile(`^approx_q(\d+(\.\d+)?)$`)
+
+// parseApproxOp parses approximate operations, e.g., approx_countunique,
+// approx_top10, approx_q95, and approx_median.
+func parseApproxOp(op string) (approxOp, bool) {
+	switch op {
+	case "approx_countunique", "approx_countuniq":
+		return approxOp{kind: approxCountUnique}, true
+	case "approx_median":
+		return approxOp{kind: approxQuantile, q: 0.5}, true
+	}
+	if m := reApproxTop.FindStringSubmatch(op); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n < 1 {
+			return approxOp{}, false
+		}
+		return approxOp{kind: approxTop, n: n}, true
+	}
+	if m := reApproxQuantile.FindStringSubmatch(op); m != nil {
+		q, err := strconv.ParseFloat(m[1], 64)
+		if err != nil || q > 100 {
+			return approxOp{}, false
+		}
+		return approxOp{kind: approxQuantile, q: q / 100}, true
+	}
+	return approxOp{}, false
+}
+
+// approxSketch holds sketches needed by approximate operations of a field.
+type approxSketch struct {
+	hll          *HyperLogLog
+	heavyHitters *HeavyHitters
+	tdigest      *TDigest
+}
+
+func newApproxSketch(ops []approxOp) *approxSketch {
+	sketch := &approxSketch{}
+	var k int
+	for _, a := range ops {
+		switch a.kind {
+		case approxCountUnique:
+			if sketch.hll == nil {
+				sketch.hll = NewHyperLogLog()
+			}
+		case approxTop:
+			if a.n > k {
+				k = a.n
+			}
+		case approxQuantile:
+			if sketch.tdigest == nil {
+				sketch.tdigest = NewTDigest()
+			}
+		}
+	}
+	if k > 0 {
+		// tracking more candidates makes the top N more accurate
+		sketch.heavyHitters = NewHeavyHitters(k * 4)
+	}
+	return sketch
+}
+
+func (s *approxSketch) addString(v string) {
+	if s.hll != nil {
+		s.hll.Add(v)
+	}
+	if s.heavyHitters != nil {
+		s.heavyHitters.Add(v)
+	}
+}
+
+// summaryAllMarker marks values of rolled up group fields.
+const summaryAllMarker = "\x00all\x00"
+
+// sortGroupsWithSubtotals sorts groups by values of group fields,
+// where rolled up values are placed after others.
+func sortGroupsWithSubtotals(groups []string) {
+	parts := make(map[string][]string, len(groups))
+	for _, g := range groups {
+		parts[g] = strings.Split(g, "_shenwei356_")
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := parts[groups[i]], parts[groups[j]]
+		for k := range a {
+			if a[k] == b[k] {
+				continue
+			}
+			if a[k] == summaryAllMarker {
+				return false
+			}
+			if b[k] == summaryAllMarker {
+				return true
+			}
+			return a[k] < b[k]
+		}
+		return false
+	})
+}
+
+// summaryAccumulator computes streaming operations incrementally.
+type summaryAccumulator struct {
+	// all values
+	count       int
+	first, last string
+	countNA     int
+
+	// numeric values
+	n              int
+	sum, prod      float64
+	min, max       float64
+	argmin, argmax int
+	mean, m2       float64 // Welford's algorithm for variance
+	sumLog, sumInv float64 // for geometric and harmonic means
+	entropy        float64 // Shannon entropy
+}
+
+func newSummaryAccumulator() *summaryAccumulator {
+	return &summaryAccumulator{prod: 1}
+}
+
+func (a *summaryAccumulator) addString(v string) {
+	a.count++
+	if a.count == 1 {
+		a.first = v
+	}
+	a.last = v
+	if _, ok := naValues[strings.ToLower(v)]; ok {
+		a.countNA++
+	}
+}
+
+func (a *summaryAccumulator) addNumber(v float64) {
+	a.n++
+	a.sum += v
+	a.prod *= v
+	if a.n == 1 || v > a.max {
+		a.max, a.argmax = v, a.n
+	}
+	if a.n == 1 || v < a.min {
+		a.min, a.argmin = v, a.n
+	}
+	d := v - a.mean
+	a.mean += d / float64(a.n)
+	a.m2 += d * (v - a.mean)
+	a.sumLog += math.Log(v)
+	a.sumInv += 1 / v
+	if v != 0 {
+		a.entropy -= v * math.Log(v)
+	}
+}
+
+// variance returns the unbiased sample variance.
+func (a *summaryAccumulator) variance() float64 {
+	if a.n < 2 {
+		return math.NaN()
+	}
+	return a.m2 / float64(a.n-1)
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestPercentileValue(t *testing.T) {
+	cases := []struct {
+		sorted     []float64
+		percentile float64
+		expect     float64
+	}{
+		{[]float64{}, 0.5, 0},
+		{[]float64{3}, 0.9, 3},
+		{[]float64{1, 2, 4}, 0, 1},
+		{[]float64{1, 2, 4}, 0.1, 1.2},
+		{[]float64{1, 2, 4}, 0.5, 2},
+		{[]float64{1, 2, 4}, 0.75, 3},
+		{[]float64{1, 2, 4}, 0.9, 3.6},
+		{[]float64{1, 2, 4}, 1, 4},
+		{[]float64{1, 2, 3, 4}, 0.5, 2.5},
+	}
+	for _, c := range cases {
+		if got := percentileValue(c.sorted, c.percentile); !floatsClose(got, c.expect) {
+			t.Errorf("percentileValue(%v, %v): want %v, got %v", c.sorted, c.percentile, c.expect, got)
+		}
+	}
+}
+
+// floatsClose tells if two floats are equal within a small tolerance.
+func floatsClose(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
+
+func TestSummary(t *testing.T) {
+	file := testFile(t, "m.csv", "g,h,v\nx,p,1\nx,q,2\ny,p,-4\nx,p,4\ny,q,8\ny,q,NA\n")
+
+	cases := []struct {
+		args   []string
+		expect string
+	}{
+		{
+			[]string{"-i", "-g", "g", "-w", "3", "-f", "v:p10,v:p90,v:iqr,v:mad,v:mode,v:countna,v:ci95_lower,v:ci95_upper"},
+			"g,v:p10,v:p90,v:iqr,v:mad,v:mode,v:countna,v:ci95_lower,v:ci95_upper\n" +
+				"x,1.200,3.600,1.500,1.000,1.000,0,-1.461,6.128\n" +
+				"y,-2.800,6.800,6.000,6.000,-4.000,1,-74.237,78.237\n",
+		},
+		{
+			[]string{"-i", "-w", "4", "-f", "v:median,v:q1,v:q3,v:cv,v:se"},
+			"v:median,v:q1,v:q3,v:cv,v:se\n2.0000,1.0000,4.0000,1.9917,1.9596\n",
+		},
+		{
+			[]string{"-i", "-f", "v:countna", "--na-values", "na,-4"},
+			"v:countna\n2\n",
+		},
+	}
+	for _, c := range cases {
+		args := append([]string{"summary", file}, c.args...)
+		if got := runCsvtk(t, args...); got != c.expect {
+			t.Errorf("csvtk %v:\nwant:\n%s\ngot:\n%s", args, c.expect, got)
+		}
+	}
+}
+
+func TestSummaryRollup(t *testing.T) {
+	file := testFile(t, "m.csv", "g,h,v\nx,p,1\nx,q,2\ny,p,-4\nx,p,4\ny,q,8\ny,q,NA\n")
+
+	cases := []struct {
+		args   []string
+		expect string
+	}{
+		{
+			[]string{"-i", "-g", "g,h", "-f", "v:sum,v:count", "--rollup"},
+			"g,h,v:sum,v:count\n" +
+				"x,p,5.00,2\nx,q,2.00,1\nx,all,7.00,3\n" +
+				"y,p,-4.00,1\ny,q,8.00,2\ny,all,4.00,3\n" +
+				"all,all,11.00,6\n",
+		},
+		{
+			[]string{"-i", "-g", "g,h", "-f", "v:sum", "--cube", "--all-label", "ALL"},
+			"g,h,v:sum\n" +
+				"x,p,5.00\nx,q,2.00\nx,ALL,7.00\n" +
+				"y,p,-4.00\ny,q,8.00\ny,ALL,4.00\n" +
+				"ALL,p,1.00\nALL,q,10.00\n" +
+				"ALL,ALL,11.00\n",
+		},
+		// percentiles are computed with values of all records in subtotals
+		{
+			[]string{"-i", "-g", "g", "-f", "v:q3,v:max", "--rollup", "-w", "1"},
+			"g,v:q3,v:max\nx,3.0,4.0\ny,5.0,8.0\nall,4.0,8.0\n",
+		},
+	}
+	for _, c := range cases {
+		args := append([]string{"summary", file}, c.args...)
+		if got := runCsvtk(t, args...); got != c.expect {
+			t.Errorf("csvtk %v:\nwant:\n%s\ngot:\n%s", args, c.expect, got)
+		}
+	}
+}
+
+func TestSummaryStreamStats(t *testing.T) {
+	r := rand.New(rand.NewSource(11))
+	random := make([]float64, 100)
+	for i := range random {
+		random[i] = float64(r.Intn(2000)-1000) / 100
+	}
+
+	// results of running accumulators should be the same as
+	// those computed with all values
+	for _, values := range [][]float64{
+		{5},
+		{1, 2, 3, 4},
+		{0, 1, 2},
+		{-2, 0.5, 3},
+		{-1, -2, -4},
+		{0.1, 0.2, 0.3, 0.4},
+		random,
+	} {
+		a := newSummaryAccumulator()
+		for _, v := range values {
+			a.addNumber(v)
+		}
+		for name, f := range streamStats {
+			expect := allStats[name](values)
+			got := f(a)
+			if math.IsNaN(expect) && math.IsNaN(got) {
+				continue
+			}
+			if math.Abs(got-expect) > 1e-9*math.Max(1, math.Abs(expect)) {
+				t.Errorf("%s of %v: want %v, got %v", name, values, expect, got)
+			}
+		}
+	}
+
+	// harmonic means of values with negatives
+	file := testFile(t, "m.csv", "v\n1\n2\n-4\n4\n8\n")
+	if got := runCsvtk(t, "summary", "-f", "v:hmean,v:mean", "-w", "4", file); got != "v:hmean,v:mean\n3.0769,2.2000\n" {
+		t.Errorf("summary -f v:hmean: unexpected output: %q", got)
+	}
+}
@@ -42,7 +42,7 @@ var tab2csvCmd = &cobra.Command{
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
 		runtime.GOMAXPROCS(config.NumCPUs)
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
@@ -65,8 +65,9 @@ var tab2csvCmd = &cobra.Command{
 			csvReader.Reader.Comma = '\t'
 
 			csvReader.Read(ReadOption{
-				FieldStr:      "1-",
-				ShowRowNumber: config.ShowRowNumber,
+				FieldStr:       "1-",
+				ShowRowNumber:  config.ShowRowNumber,
+				ShowProvenance: true,
 			})
 
 			handleHeaderRow := !config.NoHeaderRow
@@ -21,9 +21,10 @@
 package cmd
 
 import (
-	"encoding/csv"
 	"fmt"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/shenwei356/xopen"
@@ -38,6 +39,35 @@ var uniqCmd = &cobra.Command{
 	Short: "unique data without sorting",
 	Long: `unique data without sorting
 
+Attention:
+
+  1. By default, records are outputted in a streaming way. While for
+     -L/--keep-last, -R/--only-repeated, -u/--only-unique and -c/--count,
+     all kept records are stored in memory and outputted in their original
+     order at the end.
+  2. -R/--only-repeated outputs records of keys occurring more than once,
+     while -u/--only-unique outputs records of keys occurring only once.
+  3. For huge files, the memory of storing all keys can be avoided:
+     a) -s/--assume-sorted: the input is sorted (or grouped) by the keys,
+        only adjacent keys are compared, all other flags are supported.
+        Use -S/--check-sorted to check if keys are sorted in lexicographical
+        order (ascending or descending).
+     b) -b/--bloom: a Bloom filter is used to check whether a key has been
+        seen, with a false positive rate of --bloom-fpr for at most
+        --bloom-keys distinct keys. Some records with unseen keys might be
+        discarded due to false positives. Only keeping the first record
+        of each key is supported. The memory is preallocated, about 1.8 MB
+        per million keys for --bloom-fpr 0.001.
+
+Examples:
+
+  1. Keep the last record of each key:
+       csvtk uniq -f id -L
+  2. Only output duplicated records, all of them:
+       csvtk uniq -f id -R -n 1000000
+  3. Append the number of occurrences of the key:
+       csvtk uniq -f id -c
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		config := getConfigs(cmd)
@@ -56,21 +86,36 @@ var uniqCmd = &cobra.Command{
 		fuzzyFields := getFlagBool(cmd, "fuzzy-fields")
 		ignoreCase := getFlagBool(cmd, "ignore-case")
 		keepN := getFlagPositiveInt(cmd, "keep-n")
+		keepLast := getFlagBool(cmd, "keep-last")
+		onlyDup := getFlagBool(cmd, "only-repeated")
+		onlyUniq := getFlagBool(cmd, "only-unique")
+		addCount := getFlagBool(cmd, "count")
+		if onlyDup && onlyUniq {
+			checkError(fmt.Errorf("flags -R/--only-repeated and -u/--only-unique are exclusive"))
+		}
+		assumeSorted := getFlagBool(cmd, "assume-sorted")
+		checkSorted := getFlagBool(cmd, "check-sorted")
+		useBloom := getFlagBool(cmd, "bloom")
+		bloomFPR := getFlagPositiveFloat64(cmd, "bloom-fpr")
+		bloomKeys := getFlagPositiveInt(cmd, "bloom-keys")
+		if checkSorted && !assumeSorted {
+			checkError(fmt.Errorf("flag -S/--check-sorted only works with -s/--assume-sorted"))
+		}
+		if useBloom {
+			if assumeSorted {
+				checkError(fmt.Errorf("flags -s/--assume-sorted and -b/--bloom are exclusive"))
+			}
+			if keepN > 1 || keepLast || onlyDup || onlyUniq || addCount {
+				checkError(fmt.Errorf("flag -b/--bloom only supports keeping the first record of each key, i.e., -n 1, without -L/-R/-u/-c"))
+			}
+		}
+		buffered := !assumeSorted && (keepLast || onlyDup || onlyUniq || addCount)
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
-		writer := csv.NewWriter(outfh)
-		if config.OutTabs || config.Tabs {
-			if config.OutDelimiter == ',' {
-				writer.Comma = '\t'
-			} else {
-				writer.Comma = config.OutDelimiter
-			}
-		} else {
-			writer.Comma = config.OutDelimiter
-		}
+		writer := newRecordWriter(config, outfh)
 		defer func() {
 			writer.Flush()
 			checkError(writer.Error())
@@ -106,6 +151,26 @@ var uniqCmd = &cobra.Command{
 		var n int
 		var ok bool
 
+		var groups map[string]*uniqGroup // for buffered output
+		var group *uniqGroup
+		if buffered {
+			groups = make(map[string]*uniqGroup, 10000)
+		}
+		var idx int
+
+		var bloom *BloomFilter
+		if useBloom {
+			bloom, err = NewBloomFilter(uint64(bloomKeys), bloomFPR)
+			checkError(err)
+		}
+
+		// for sorted input
+		var preKey string
+		var direction, cmp int // 1 for ascending, -1 for descending
+		if assumeSorted {
+			group = &uniqGroup{records: make([]uniqRecord, 0, keepN)}
+		}
+
 		checkFirstLine := true
 		for record := range csvReader.Ch {
 			if record.Err != nil {
@@ -119,6 +184,9 @@ var uniqCmd = &cobra.Command{
 					if config.NoOutHeader {
 						continue
 					}
+					if addCount {
+						record.All = append(record.All, "count")
+					}
 					checkError(writer.Write(record.All))
 					continue
 				}
@@ -128,6 +196,43 @@ var uniqCmd = &cobra.Command{
 			if ignoreCase {
 				key = strings.ToLower(key)
 			}
+
+			if assumeSorted {
+				if group.count > 0 && key != preKey {
+					if checkSorted {
+						cmp = strings.Compare(key, preKey)
+						if direction == 0 {
+							direction = cmp
+						} else if cmp != direction {
+							checkError(fmt.Errorf("input not sorted by keys, line %d: %s", record.Line, strings.Join(record.Selected, ",")))
+						}
+					}
+					group.write(writer, onlyDup, onlyUniq, addCount)
+					group.count = 0
+					group.records = group.records[:0]
+				}
+				preKey = key
+				group.add(record.All, idx, keepN, keepLast)
+				continue
+			}
+
+			if useBloom {
+				if !bloom.Add(key) {
+					checkError(writer.Write(record.All))
+				}
+				continue
+			}
+
+			if buffered {
+				if group, ok = groups[key]; !ok {
+					group = &uniqGroup{records: make([]uniqRecord, 0, 1)}
+					groups[key] = group
+				}
+				group.add(record.All, idx, keepN, keepLast)
+				idx++
+				continue
+			}
+
 			if n, ok = keysMaps[key]; ok {
 				if n >= keepN {
 					continue
@@ -139,15 +244,86 @@ var uniqCmd = &cobra.Command{
 			checkError(writer.Write(record.All))
 		}
 
+		if assumeSorted && group.count > 0 {
+			group.write(writer, onlyDup, onlyUniq, addCount)
+		}
+
+		if buffered {
+			records := make([]uniqRecord, 0, len(groups))
+			var r uniqRecord
+			for _, group = range groups {
+				if (onlyDup && group.count == 1) || (onlyUniq && group.count > 1) {
+					continue
+				}
+				for _, r = range group.records {
+					if addCount {
+						r.record = append(r.record, strconv.Itoa(group.count))
+					}
+					records = append(records, r)
+				}
+			}
+			sort.Slice(records, func(i, j int) bool { return records[i].idx < records[j].idx })
+
+			for _, r = range records {
+				checkError(writer.Write(r.record))
+			}
+		}
+
 		readerReport(&config, csvReader, file)
 	},
 }
 
+type uniqRecord struct {
+	idx    int // index of the record, for keeping the original order
+	record []string
+}
+
+type uniqGroup struct {
+	count   int // the number of occurrences of the key
+	records []uniqRecord
+}
+
+// add counts a record of the key, and keeps it if it's one of the first (or last) N records.
+func (g *uniqGroup) add(record []string, idx int, keepN int, keepLast bool) {
+	g.count++
+	if keepLast {
+		if len(g.records) == keepN {
+			copy(g.records, g.records[1:])
+			g.records = g.records[:keepN-1]
+		}
+		g.records = append(g.records, uniqRecord{idx: idx, record: record})
+	} else if len(g.records) < keepN {
+		g.records = append(g.records, uniqRecord{idx: idx, record: record})
+	}
+}
+
+// write outputs the kept records of a key.
+func (g *uniqGroup) write(writer RecordWriter, onlyDup, onlyUniq, addCount bool) {
+	if (onlyDup && g.count == 1) || (onlyUniq && g.count > 1) {
+		return
+	}
+	for _, r := range g.records {
+		if addCount {
+			r.record = append(r.record, strconv.Itoa(g.count))
+		}
+		checkError(writer.Write(r.record))
+	}
+}
+
 func init() {
 	RootCmd.AddCommand(uniqCmd)
 	uniqCmd.Flags().StringP("fields", "f", "1", `select these fields as keys. e.g -f 1,2 or -f columnA,columnB`)
 	uniqCmd.Flags().BoolP("ignore-case", "i", false, `ignore case`)
 	uniqCmd.Flags().BoolP("fuzzy-fields", "F", false, `using fuzzy fields, e.g., -F -f "*name" or -F -f "id123*"`)
 	uniqCmd.Flags().IntP("keep-n", "n", 1, `keep at most N records for a key`)
+	uniqCmd.Flags().BoolP("keep-last", "L", false, `keep the last N records for a key, instead of the first N ones`)
+	uniqCmd.Flags().BoolP("only-repeated", "R", false, `only output records of keys occurring more than once`)
+	uniqCmd.Flags().BoolP("only-unique", "u", false, `only output records of keys occurring only once`)
+	uniqCmd.Flags().BoolP("assume-sorted", "s", false, `assume the input is sorted (grouped) by keys, only adjacent keys are compared, using constant memory`)
+	uniqCmd.Flags().BoolP("check-sorted", "S", false, `check if keys are sorted in lexicographical order, for -s/--assume-sorted`)
+	uniqCmd.Flags().BoolP("bloom", "b", false, `use a Bloom filter to check seen keys, using bounded memory with a false positive rate`)
+	uniqCmd.Flags().Float64P("bloom-fpr", "", 0.001, `false positive rate of the Bloom filter, for -b/--bloom`)
+	uniqCmd.Flags().IntP("bloom-keys", "", 10000000, `expected maximum number of distinct keys, for -b/--bloom`)
+	uniqCmd.Flags().BoolP("count", "c", false, `append a column ("count") of the number of occurrences of the key`)
 
 }
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestUniq(t *testing.T) {
+	file := testFile(t, "u.csv", "k,v\na,1\nb,2\nB,3\nc,4\na,5\nb,6\n")
+
+	cases := []struct {
+		args   []string
+		expect string
+	}{
+		{[]string{"-f", "k"}, "k,v\na,1\nb,2\nB,3\nc,4\n"},
+		{[]string{"-f", "k", "-i"}, "k,v\na,1\nb,2\nc,4\n"},
+		{[]string{"-f", "k", "-i", "-n", "2"}, "k,v\na,1\nb,2\nB,3\nc,4\na,5\n"},
+		{[]string{"-f", "k", "-i", "-L"}, "k,v\nc,4\na,5\nb,6\n"},
+		{[]string{"-f", "k", "-i", "-L", "-n", "2"}, "k,v\na,1\nB,3\nc,4\na,5\nb,6\n"},
+		// records are outputted in their original order
+		{[]string{"-f", "k", "-i", "-R"}, "k,v\na,1\nb,2\n"},
+		{[]string{"-f", "k", "-i", "-R", "-n", "2"}, "k,v\na,1\nb,2\nB,3\na,5\n"},
+		{[]string{"-f", "k", "-i", "-R", "-L"}, "k,v\na,5\nb,6\n"},
+		{[]string{"-f", "k", "-i", "-u"}, "k,v\nc,4\n"},
+		{[]string{"-f", "k", "-u"}, "k,v\nB,3\nc,4\n"},
+		{[]string{"-f", "k", "-i", "-c"}, "k,v,count\na,1,2\nb,2,3\nc,4,1\n"},
+		{[]string{"-f", "k", "-i", "-R", "-c", "-U"}, "a,1,2\nb,2,3\n"},
+		{[]string{"-f", "1", "-H", "-i", "-c"}, "k,v,1\na,1,2\nb,2,3\nc,4,1\n"},
+	}
+	for _, c := range cases {
+		args := append([]string{"uniq", file}, c.args...)
+		if got := runCsvtk(t, args...); got != c.expect {
+			t.Errorf("csvtk %v:\nwant:\n%s\ngot:\n%s", args, c.expect, got)
+		}
+	}
+}
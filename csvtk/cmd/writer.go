@@ -0,0 +1,370 @@
+// Copyright © 2016-2023 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/shenwei356/stable"
+	"github.com/xuri/excelize/v2"
+)
+
+// RecordWriter writes records to the output. It's satisfied by *csv.Writer,
+// so commands can switch output formats without changing how they write records.
+type RecordWriter interface {
+	Write(record []string) error
+	Flush()
+	Error() error
+}
+
+// OutFormats are the supported output formats.
+var OutFormats = []string{"csv", "tsv", "ndjson", "markdown", "pretty", "xlsx"}
+
+func checkOutFormat(format string) (string, error) {
+	format = strings.ToLower(format)
+	switch format {
+	case "jsonl":
+		format = "ndjson"
+	case "md":
+		format = "markdown"
+	}
+	for _, f := range OutFormats {
+		if format == f {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported output format: %s. available values: %s", format, strings.Join(OutFormats, ", "))
+}
+
+// getOutDelimiter returns the delimiter of the output CSV/TSV.
+func getOutDelimiter(config Config) rune {
+	if config.OutTabs || config.Tabs {
+		if config.OutDelimiter == ',' { // default value, no other value given
+			return '\t'
+		}
+	}
+	return config.OutDelimiter
+}
+
+// newRecordWriter returns a RecordWriter of the output format given by
+// the global flag --out-format. For formats requiring column names,
+// the first record is treated as the header row, unless -H/--no-header-row
+// or -U/--delete-header is given.
+//
+// Formats of markdown, pretty and xlsx need all records to render the output,
+// so Flush does nothing for them, and the output is rendered only once when
+// the output file (an *OutWriter) is closed.
+func newRecordWriter(config Config, w io.Writer) RecordWriter {
+	hasHeaderRow := !config.NoHeaderRow && !config.NoOutHeader
+	switch config.OutFormat {
+	case "tsv":
+		writer := csv.NewWriter(w)
+		writer.Comma = '\t'
+		return writer
+	case "ndjson":
+		return &ndjsonWriter{w: w, hasHeaderRow: hasHeaderRow}
+	case "markdown":
+		writer := &markdownWriter{w: w}
+		renderOnClose(w, writer.render)
+		return writer
+	case "pretty":
+		writer := &prettyWriter{w: w, hasHeaderRow: hasHeaderRow}
+		renderOnClose(w, writer.render)
+		return writer
+	case "xlsx":
+		writer := &xlsxWriter{w: w, hasHeaderRow: hasHeaderRow}
+		renderOnClose(w, writer.render)
+		return writer
+	default:
+		writer := csv.NewWriter(w)
+		writer.Comma = getOutDelimiter(config)
+		return writer
+	}
+}
+
+// isBufferedOutFormat tells if all records are buffered and rendered
+// when the output file is closed.
+func isBufferedOutFormat(format string) bool {
+	switch format {
+	case "markdown", "pretty", "xlsx":
+		return true
+	}
+	return false
+}
+
+// renderOnClose registers a function rendering buffered records,
+// which is called before the output file is closed.
+func renderOnClose(w io.Writer, render func() error) {
+	if outfh, ok := w.(*OutWriter); ok {
+		outfh.beforeClose = append(outfh.beforeClose, render)
+	}
+}
+
+// ndjsonWriter writes each record as a JSON object in one line,
+// or a JSON array if there's no header row.
+type ndjsonWriter struct {
+	w            io.Writer
+	hasHeaderRow bool
+	header       [][]byte // JSON encoded column names
+	buf          bytes.Buffer
+	err          error
+}
+
+func (w *ndjsonWriter) Write(record []string) error {
+	if w.err != nil {
+		return w.err
+	}
+	var b []byte
+	var err error
+	if w.hasHeaderRow && w.header == nil {
+		w.header = make([][]byte, len(record))
+		for i, c := range record {
+			if b, err = json.Marshal(c); err != nil {
+				w.err = err
+				return err
+			}
+			w.header[i] = b
+		}
+		return nil
+	}
+
+	w.buf.Reset()
+	if w.hasHeaderRow {
+		if len(record) != len(w.header) {
+			w.err = fmt.Errorf("number of fields (%d) and column names (%d) do not match", len(record), len(w.header))
+			return w.err
+		}
+		w.buf.WriteByte('{')
+	} else {
+		w.buf.WriteByte('[')
+	}
+	for i, c := range record {
+		if i > 0 {
+			w.buf.WriteByte(',')
+		}
+		if w.hasHeaderRow {
+			w.buf.Write(w.header[i])
+			w.buf.WriteByte(':')
+		}
+		if b, err = json.Marshal(c); err != nil {
+			w.err = err
+			return err
+		}
+		w.buf.Write(b)
+	}
+	if w.hasHeaderRow {
+		w.buf.WriteString("}\n")
+	} else {
+		w.buf.WriteString("]\n")
+	}
+	_, w.err = w.w.Write(w.buf.Bytes())
+	return w.err
+}
+
+func (w *ndjsonWriter) Flush() {}
+
+func (w *ndjsonWriter) Error() error { return w.err }
+
+// markdownWriter buffers all records and writes a markdown table in render.
+// The first record is always used as the header row.
+type markdownWriter struct {
+	w       io.Writer
+	records [][]string
+	err     error
+}
+
+func (w *markdownWriter) Write(record []string) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.records = append(w.records, append([]string(nil), record...))
+	return nil
+}
+
+func (w *markdownWriter) Flush() {}
+
+func (w *markdownWriter) render() error {
+	if w.err != nil || len(w.records) == 0 {
+		return w.err
+	}
+
+	var ncols int
+	for _, record := range w.records {
+		if len(record) > ncols {
+			ncols = len(record)
+		}
+	}
+	widths := make([]int, ncols)
+	for i := range widths {
+		widths[i] = 3
+	}
+	var l int
+	for _, record := range w.records {
+		for i, c := range record {
+			c = strings.ReplaceAll(c, "|", `\|`)
+			record[i] = c
+			if l = runewidth.StringWidth(c); l > widths[i] {
+				widths[i] = l
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	writeRow := func(record []string) {
+		buf.WriteString("|")
+		var c string
+		for i, width := range widths {
+			c = ""
+			if i < len(record) {
+				c = record[i]
+			}
+			buf.WriteString(" ")
+			buf.WriteString(c)
+			buf.WriteString(strings.Repeat(" ", width-runewidth.StringWidth(c)))
+			buf.WriteString(" |")
+		}
+		buf.WriteString("\n")
+	}
+
+	writeRow(w.records[0])
+	buf.WriteString("|")
+	for _, width := range widths {
+		buf.WriteString(" ")
+		buf.WriteString(strings.Repeat("-", width))
+		buf.WriteString(" |")
+	}
+	buf.WriteString("\n")
+	for _, record := range w.records[1:] {
+		writeRow(record)
+	}
+
+	_, w.err = w.w.Write(buf.Bytes())
+	w.records = nil
+	return w.err
+}
+
+func (w *markdownWriter) Error() error { return w.err }
+
+// prettyWriter writes records as an aligned table, with the same default
+// style of "csvtk pretty".
+type prettyWriter struct {
+	w            io.Writer
+	hasHeaderRow bool
+	tbl          *stable.Table
+	err          error
+}
+
+func (w *prettyWriter) Write(record []string) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.tbl == nil {
+		w.tbl = stable.New()
+		w.tbl.Style(prettyDefaultStyle("   "))
+		w.tbl.Writer(w.w, math.MaxUint)
+
+		header := make([]stable.Column, len(record))
+		if w.hasHeaderRow {
+			for i, c := range record {
+				header[i].Header = c
+			}
+			w.tbl.HeaderWithFormat(header)
+			return nil
+		}
+		w.tbl.HeaderWithFormat(header)
+	}
+	w.tbl.AddRowStringSlice(record)
+	return nil
+}
+
+func (w *prettyWriter) Flush() {}
+
+func (w *prettyWriter) render() error {
+	if w.tbl != nil {
+		w.tbl.Flush()
+		w.tbl = nil
+	}
+	return w.err
+}
+
+func (w *prettyWriter) Error() error { return w.err }
+
+// xlsxWriter writes records into the first sheet of an Excel file,
+// which is written in render.
+type xlsxWriter struct {
+	w            io.Writer
+	hasHeaderRow bool
+	xlsx         *excelize.File
+	line         int
+	err          error
+}
+
+func (w *xlsxWriter) Write(record []string) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.xlsx == nil {
+		w.xlsx = excelize.NewFile()
+		if w.hasHeaderRow {
+			w.err = w.xlsx.SetPanes("Sheet1", &excelize.Panes{
+				Freeze:      true,
+				Split:       false,
+				XSplit:      0,
+				YSplit:      1,
+				TopLeftCell: "A2",
+				ActivePane:  "bottomLeft",
+			})
+			if w.err != nil {
+				return w.err
+			}
+		}
+	}
+	w.line++
+	for col, val := range record {
+		if w.err = w.xlsx.SetCellValue("Sheet1", fmt.Sprintf("%s%d", ExcelColumnIndex(col), w.line), val); w.err != nil {
+			return w.err
+		}
+	}
+	return nil
+}
+
+func (w *xlsxWriter) Flush() {}
+
+func (w *xlsxWriter) render() error {
+	if w.err != nil || w.xlsx == nil {
+		return w.err
+	}
+	w.err = w.xlsx.Write(w.w)
+	if err := w.xlsx.Close(); err != nil && w.err == nil {
+		w.err = err
+	}
+	w.xlsx = nil
+	return w.err
+}
+
+func (w *xlsxWriter) Error() error { return w.err }
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestNewRecordWriter(t *testing.T) {
+	records := [][]string{
+		{"id", "name"},
+		{"1", `a"b`},
+		{"2", "c|d"},
+	}
+
+	cases := []struct {
+		format      string
+		noHeaderRow bool
+		outTabs     bool
+		expect      string
+	}{
+		{
+			format: "csv",
+			expect: "id,name\n1,\"a\"\"b\"\n2,c|d\n",
+		},
+		{
+			format:  "csv",
+			outTabs: true,
+			expect:  "id\tname\n1\t\"a\"\"b\"\n2\tc|d\n",
+		},
+		{
+			format: "tsv",
+			expect: "id\tname\n1\t\"a\"\"b\"\n2\tc|d\n",
+		},
+		{
+			format: "ndjson",
+			expect: `{"id":"1","name":"a\"b"}` + "\n" + `{"id":"2","name":"c|d"}` + "\n",
+		},
+		{
+			format:      "ndjson",
+			noHeaderRow: true,
+			expect:      `["id","name"]` + "\n" + `["1","a\"b"]` + "\n" + `["2","c|d"]` + "\n",
+		},
+		{
+			format: "markdown",
+			expect: "| id  | name |\n| --- | ---- |\n| 1   | a\"b  |\n| 2   | c\\|d |\n",
+		},
+	}
+
+	for _, c := range cases {
+		config := Config{
+			OutDelimiter: ',',
+			OutFormat:    c.format,
+			OutTabs:      c.outTabs,
+			NoHeaderRow:  c.noHeaderRow,
+		}
+
+		data, err := writeRecordsToFile(t, config, records)
+		if err != nil {
+			t.Fatalf("format %s: %s", c.format, err)
+		}
+		if string(data) != c.expect {
+			t.Errorf("format %s (no header row: %v, out tabs: %v):\nwant:\n\t%q\ngot:\n\t%q\n",
+				c.format, c.noHeaderRow, c.outTabs, c.expect, data)
+		}
+	}
+
+	// ndjson: the number of fields should match that of column names
+	var buf bytes.Buffer
+	writer := newRecordWriter(Config{OutFormat: "ndjson"}, &buf)
+	writer.Write([]string{"a", "b"})
+	if err := writer.Write([]string{"1"}); err == nil {
+		t.Errorf("ndjson: error expected for unmatched number of fields")
+	}
+
+	// xlsx: only one workbook is written, even if flushed many times
+	data, err := writeRecordsToFile(t, Config{OutFormat: "xlsx"}, records)
+	if err != nil {
+		t.Fatalf("xlsx: %s", err)
+	}
+	xlsx, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("xlsx: %s", err)
+	}
+	rows, err := xlsx.GetRows("Sheet1")
+	if err != nil {
+		t.Fatalf("xlsx: %s", err)
+	}
+	if !reflect.DeepEqual(rows, records) {
+		t.Errorf("xlsx: want %v, got %v", records, rows)
+	}
+}
+
+// writeRecordsToFile writes records to an output file, with the writer
+// flushed after each record, e.g., in "csvtk grep --immediate-output",
+// and returns the content of the file.
+func writeRecordsToFile(t *testing.T, config Config, records [][]string) ([]byte, error) {
+	file := filepath.Join(t.TempDir(), "out."+config.OutFormat)
+	outfh, err := wopen(file, "")
+	if err != nil {
+		return nil, err
+	}
+	writer := newRecordWriter(config, outfh)
+	for _, record := range records {
+		if err = writer.Write(record); err != nil {
+			return nil, err
+		}
+		writer.Flush()
+		if err = writer.Error(); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	if err = outfh.Close(); err != nil {
+		return nil, err
+	}
+	if err = writer.Error(); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(file)
+}
+
+func TestCheckOutFormat(t *testing.T) {
+	cases := []struct {
+		format string
+		expect string
+		err    bool
+	}{
+		{"csv", "csv", false},
+		{"TSV", "tsv", false},
+		{"jsonl", "ndjson", false},
+		{"md", "markdown", false},
+		{"xlsx", "xlsx", false},
+		{"json", "", true},
+	}
+	for _, c := range cases {
+		format, err := checkOutFormat(c.format)
+		if (err != nil) != c.err || format != c.expect {
+			t.Errorf("checkOutFormat(%q): want %q (error: %v), got %q (error: %v)", c.format, c.expect, c.err, format, err)
+		}
+	}
+}
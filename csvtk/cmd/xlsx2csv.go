@@ -21,12 +21,10 @@
 package cmd
 
 import (
-	"encoding/csv"
 	"fmt"
 	"runtime"
 	"sort"
 
-	"github.com/shenwei356/xopen"
 	"github.com/spf13/cobra"
 	"github.com/xuri/excelize/v2"
 )
@@ -56,7 +54,7 @@ var xlsx2csvCmd = &cobra.Command{
 		sheetName := getFlagString(cmd, "sheet-name")
 		sheetIndex := getFlagPositiveInt(cmd, "sheet-index")
 
-		outfh, err := xopen.Wopen(config.OutFile)
+		outfh, err := wopenByConfig(config)
 		checkError(err)
 		defer outfh.Close()
 
@@ -97,16 +95,7 @@ var xlsx2csvCmd = &cobra.Command{
 			}
 		}
 
-		writer := csv.NewWriter(outfh)
-		if config.OutTabs || config.Tabs {
-			if config.OutDelimiter == ',' {
-				writer.Comma = '\t'
-			} else {
-				writer.Comma = config.OutDelimiter
-			}
-		} else {
-			writer.Comma = config.OutDelimiter
-		}
+		writer := newRecordWriter(config, outfh)
 		defer func() {
 			writer.Flush()
 			checkError(writer.Error())